@@ -0,0 +1,293 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	stdurl "net/url"
+	"sync"
+	"time"
+)
+
+const defaultBatchConcurrency = 10
+
+// ErrBatchStopped WithBatchStopOnError 时 出错后没有发送的请求返回这个错误
+var ErrBatchStopped = errors.New("httpclient: batch stopped after an error")
+
+// ErrBatchClientMismatch Client.DoBatch 中不是这个 client 创建的请求不发送 返回这个错误
+var ErrBatchClientMismatch = errors.New("httpclient: batch request belongs to another client")
+
+// BatchResult 批量请求中一个请求的结果 Index 为请求在参数中的位置
+type BatchResult struct {
+	Index    int
+	Request  *Request
+	Response *Response // body 已经读取到内存
+	Err      error
+}
+
+// Executor 并发发送请求 限制总并发数、每个host的并发数和请求频率
+// 限制在多次调用之间共享 长期使用同一个 Executor 可以对所有请求限流
+type Executor struct {
+	sem         chan struct{}
+	hostLimit   int
+	hostRate    float64 // 每秒的请求数 0 不限制
+	hostBurst   int
+	stopOnError bool
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimiter
+}
+
+type ExecutorOption func(*Executor)
+
+// WithBatchConcurrency 同时发送的最大请求数 默认10
+func WithBatchConcurrency(n int) ExecutorOption {
+	return func(e *Executor) {
+		if n > 0 {
+			e.sem = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithBatchHostConcurrency 每个host同时发送的最大请求数 <=0 不限制
+func WithBatchHostConcurrency(n int) ExecutorOption {
+	return func(e *Executor) {
+		e.hostLimit = n
+	}
+}
+
+// WithBatchHostRate 每个host每秒最多 limit 个请求 burst 为允许的突发请求数 最小为1
+func WithBatchHostRate(limit float64, burst int) ExecutorOption {
+	return func(e *Executor) {
+		e.hostRate = limit
+		e.hostBurst = max(burst, 1)
+	}
+}
+
+// WithBatchStopOnError 有请求出错时取消正在发送的请求 不再发送剩下的请求
+func WithBatchStopOnError(stop bool) ExecutorOption {
+	return func(e *Executor) {
+		e.stopOnError = stop
+	}
+}
+
+func NewExecutor(opts ...ExecutorOption) *Executor {
+	e := &Executor{
+		sem:   make(chan struct{}, defaultBatchConcurrency),
+		hosts: make(map[string]*hostLimiter),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// DoBatch 使用新的 Executor 并发发送请求 结果的顺序和 reqs 相同
+// 请求必须是 c 创建的 其他 client 创建的请求返回 ErrBatchClientMismatch
+func (c *Client) DoBatch(ctx context.Context, reqs []*Request, opts ...ExecutorOption) []BatchResult {
+	return collectBatch(NewExecutor(opts...).doChan(ctx, c, reqs), len(reqs))
+}
+
+// DoBatchChan 同 DoBatch 按完成的顺序返回结果
+func (c *Client) DoBatchChan(ctx context.Context, reqs []*Request, opts ...ExecutorOption) <-chan BatchResult {
+	return NewExecutor(opts...).doChan(ctx, c, reqs)
+}
+
+// Do 并发发送请求 等待全部完成 结果的顺序和 reqs 相同
+// ctx 取消后没有发送的请求返回 ctx 的错误
+func (e *Executor) Do(ctx context.Context, reqs []*Request) []BatchResult {
+	return collectBatch(e.DoChan(ctx, reqs), len(reqs))
+}
+
+// DoChan 并发发送请求 按完成的顺序返回结果 全部完成后关闭 channel
+// channel 的容量为请求数 不读取也不会阻塞发送
+func (e *Executor) DoChan(ctx context.Context, reqs []*Request) <-chan BatchResult {
+	return e.doChan(ctx, nil, reqs)
+}
+
+func collectBatch(ch <-chan BatchResult, n int) []BatchResult {
+	results := make([]BatchResult, n)
+	for result := range ch {
+		results[result.Index] = result
+	}
+	return results
+}
+
+// doChan 按 host 分组 每个 host 按顺序发送 同时运行的 goroutine 数不超过 host 的并发数和总并发数
+// 等待某个 host 时只阻塞这个 host 的 goroutine client 不为空时只发送 client 创建的请求
+func (e *Executor) doChan(ctx context.Context, client *Client, reqs []*Request) <-chan BatchResult {
+	ch := make(chan BatchResult, len(reqs))
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	var (
+		hosts  []*hostLimiter
+		queues = make(map[*hostLimiter][]int)
+	)
+	for i, req := range reqs {
+		if client != nil && req.client != client {
+			ch <- BatchResult{Index: i, Request: req, Err: ErrBatchClientMismatch}
+			if e.stopOnError {
+				cancel(ErrBatchStopped)
+			}
+			continue
+		}
+		host := e.hostLimiter(req)
+		if _, ok := queues[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		queues[host] = append(queues[host], i)
+	}
+
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		queue := make(chan int, len(queues[host]))
+		for _, i := range queues[host] {
+			queue <- i
+		}
+		close(queue)
+
+		workers := min(len(queues[host]), cap(e.sem))
+		if host.sem != nil {
+			workers = min(workers, cap(host.sem))
+		}
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range queue {
+					result := e.do(ctx, i, reqs[i])
+					if result.Err != nil && e.stopOnError {
+						cancel(ErrBatchStopped)
+					}
+					ch <- result
+				}
+			}()
+		}
+	}
+	go func() {
+		wg.Wait()
+		cancel(nil)
+		close(ch)
+	}()
+	return ch
+}
+
+// do 依次等待 host 的并发数、请求频率和总并发数 避免等待某个 host 时占用总并发数
+func (e *Executor) do(ctx context.Context, index int, req *Request) (result BatchResult) {
+	result = BatchResult{Index: index, Request: req}
+
+	host := e.hostLimiter(req)
+	if host.sem != nil {
+		if err := acquire(ctx, host.sem); err != nil {
+			result.Err = err
+			return result
+		}
+		defer func() { <-host.sem }()
+	}
+	if err := host.wait(ctx); err != nil {
+		result.Err = err
+		return result
+	}
+	if err := acquire(ctx, e.sem); err != nil {
+		result.Err = err
+		return result
+	}
+	defer func() { <-e.sem }()
+
+	// 保留请求自己的 context 批量的 ctx 取消时也取消请求
+	reqCtx := req.Context()
+	if reqCtx == nil {
+		reqCtx = context.Background()
+	}
+	reqCtx, cancel := context.WithCancelCause(reqCtx)
+	defer cancel(nil)
+	stop := context.AfterFunc(ctx, func() {
+		cancel(context.Cause(ctx))
+	})
+	defer stop()
+
+	// 复制请求 不修改调用方的 context
+	result.Response, result.Err = req.clone().WithContext(reqCtx).Do()
+	if result.Err == nil {
+		// 在 context 取消前读取 body
+		_, result.Err = result.Response.Body()
+	}
+	if result.Err != nil && context.Cause(ctx) != nil {
+		result.Err = context.Cause(ctx)
+	}
+	return result
+}
+
+// acquire ctx 取消时返回取消的原因
+func acquire(ctx context.Context, sem chan struct{}) error {
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	default:
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+func (e *Executor) hostLimiter(req *Request) *hostLimiter {
+	var host string
+	if u, err := stdurl.Parse(req.url); err == nil {
+		host = u.Host
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	h, ok := e.hosts[host]
+	if !ok {
+		h = &hostLimiter{rate: e.hostRate, burst: float64(e.hostBurst), tokens: float64(e.hostBurst)}
+		if e.hostLimit > 0 {
+			h.sem = make(chan struct{}, e.hostLimit)
+		}
+		e.hosts[host] = h
+	}
+	return h
+}
+
+// hostLimiter 一个 host 的并发数和令牌桶
+type hostLimiter struct {
+	sem chan struct{} // nil 不限制并发
+
+	rate, burst float64 // rate 为 0 不限制频率
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// wait 预留一个令牌 令牌不足时等待 ctx 取消时归还令牌
+func (h *hostLimiter) wait(ctx context.Context) error {
+	if h.rate <= 0 {
+		return nil
+	}
+
+	h.mu.Lock()
+	now := time.Now()
+	if !h.last.IsZero() {
+		h.tokens = min(h.burst, h.tokens+now.Sub(h.last).Seconds()*h.rate)
+	}
+	h.last = now
+	h.tokens--
+	delay := time.Duration(-h.tokens / h.rate * float64(time.Second))
+	h.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	if err := sleepContext(ctx, delay); err != nil {
+		h.mu.Lock()
+		h.tokens++
+		h.mu.Unlock()
+		return context.Cause(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newConcurrencyServer 记录同时处理的最大请求数 响应为查询参数 i
+func newConcurrencyServer(delay time.Duration) (*httptest.Server, *atomic.Int32) {
+	var active, peak atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(delay)
+		fmt.Fprint(w, r.URL.Query().Get("i"))
+	}))
+	return s, &peak
+}
+
+func TestExecutor_Limits(t *testing.T) {
+	s1, peak1 := newConcurrencyServer(20 * time.Millisecond)
+	defer s1.Close()
+	s2, peak2 := newConcurrencyServer(20 * time.Millisecond)
+	defer s2.Close()
+
+	client := NewClient()
+	var reqs []*Request
+	for i := 0; i < 12; i++ {
+		s := s1
+		if i%3 == 0 {
+			s = s2
+		}
+		reqs = append(reqs, client.NewRequest(http.MethodGet, s.URL).SetQueryParam("i", fmt.Sprint(i)))
+	}
+
+	results := client.DoBatch(context.Background(), reqs, WithBatchConcurrency(3), WithBatchHostConcurrency(2))
+	for i, result := range results {
+		body, _ := result.Response.Body()
+		if result.Err != nil || result.Index != i || result.Request != reqs[i] || string(body) != fmt.Sprint(i) {
+			t.Fatalf("%d: index = %d, body = %q, err = %v", i, result.Index, body, result.Err)
+		}
+	}
+	if peak1.Load() > 2 || peak2.Load() > 2 || peak1.Load()+peak2.Load() < 2 {
+		t.Fatalf("peak = %d, %d", peak1.Load(), peak2.Load())
+	}
+
+	peak1.Store(0)
+	count := 0
+	for result := range client.DoBatchChan(context.Background(), reqs[1:3], WithBatchConcurrency(1)) {
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		count++
+	}
+	if count != 2 || peak1.Load() != 1 {
+		t.Fatalf("count = %d, peak = %d", count, peak1.Load())
+	}
+}
+
+func TestExecutor_HostRate(t *testing.T) {
+	s, _ := newConcurrencyServer(0)
+	defer s.Close()
+
+	reqs := make([]*Request, 6)
+	for i := range reqs {
+		reqs[i] = NewRequest(http.MethodGet, s.URL)
+	}
+	start := time.Now()
+	for _, result := range NewExecutor(WithBatchHostRate(50, 1)).Do(context.Background(), reqs) {
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+	}
+	// 第一个请求使用 burst 之后每 20ms 一个
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Fatalf("elapsed = %v, want about 100ms", elapsed)
+	}
+}
+
+func TestExecutor_Stop(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			panic(http.ErrAbortHandler)
+		}
+		<-r.Context().Done()
+	}))
+	defer s.Close()
+
+	newReqs := func(fail bool) []*Request {
+		reqs := make([]*Request, 5)
+		for i := range reqs {
+			reqs[i] = NewRequest(http.MethodGet, s.URL)
+		}
+		if fail {
+			reqs[0].SetQueryParam("fail", "1")
+		}
+		return reqs
+	}
+
+	results := NewExecutor(WithBatchStopOnError(true)).Do(context.Background(), newReqs(true))
+	if results[0].Err == nil || errors.Is(results[0].Err, ErrBatchStopped) {
+		t.Fatalf("first error = %v", results[0].Err)
+	}
+	for _, result := range results[1:] {
+		if !errors.Is(result.Err, ErrBatchStopped) {
+			t.Fatalf("err = %v, want ErrBatchStopped", result.Err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	for _, result := range NewExecutor().Do(ctx, newReqs(false)) {
+		if !errors.Is(result.Err, context.DeadlineExceeded) {
+			t.Fatalf("err = %v, want deadline exceeded", result.Err)
+		}
+	}
+}
+
+func TestExecutor_Bounded(t *testing.T) {
+	var started atomic.Int32
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started.Add(1)
+		<-release
+	}))
+	defer s.Close()
+
+	client := NewClient()
+	reqs := make([]*Request, 50)
+	for i := range reqs {
+		reqs[i] = client.NewRequest(http.MethodGet, s.URL)
+	}
+	reqs[1] = NewRequest(http.MethodGet, s.URL)
+
+	base := runtime.NumGoroutine()
+	ch := client.DoBatchChan(context.Background(), reqs, WithBatchConcurrency(2), WithBatchHostConcurrency(1))
+	for started.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// 一个 host 的 goroutine 数不超过 host 的并发数
+	if n := runtime.NumGoroutine() - base; n > 20 {
+		t.Fatalf("goroutines = %d", n)
+	}
+	close(release)
+
+	count := 0
+	for result := range ch {
+		if (result.Index == 1) != errors.Is(result.Err, ErrBatchClientMismatch) {
+			t.Fatalf("%d: err = %v", result.Index, result.Err)
+		}
+		count++
+	}
+	if count != len(reqs) || started.Load() != int32(len(reqs)-1) {
+		t.Fatalf("count = %d, started = %d", count, started.Load())
+	}
+}
+
+func TestExecutor_HostIsolation(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+	fast, peak := newConcurrencyServer(5 * time.Millisecond)
+	defer fast.Close()
+
+	client := NewClient()
+	var reqs []*Request
+	for i := 0; i < 5; i++ {
+		reqs = append(reqs, client.NewRequest(http.MethodGet, slow.URL))
+	}
+	for i := 0; i < 5; i++ {
+		reqs = append(reqs, client.NewRequest(http.MethodGet, fmt.Sprintf("%s?i=%d", fast.URL, i)))
+	}
+
+	// 慢的 host 阻塞时 其他 host 的请求不用排在后面
+	ch := client.DoBatchChan(context.Background(), reqs, WithBatchConcurrency(4), WithBatchHostConcurrency(1))
+	timeout := time.After(5 * time.Second)
+	for done := 0; done < 5; done++ {
+		select {
+		case result := <-ch:
+			if result.Index < 5 || result.Err != nil {
+				t.Fatalf("%d: err = %v", result.Index, result.Err)
+			}
+		case <-timeout:
+			t.Fatalf("fast host finished %d requests behind the slow host", done)
+		}
+	}
+	if peak.Load() != 1 {
+		t.Fatalf("fast host peak = %d, want 1", peak.Load())
+	}
+}
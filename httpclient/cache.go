@@ -0,0 +1,516 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 默认缓存的最大 body 超过时不缓存
+const defaultCacheMaxBodySize = 10 << 20
+
+// 默认可以缓存的状态码 RFC 7231 6.1
+var cacheableStatusCodes = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// WithCache 按 RFC 7234 缓存 GET 和 HEAD 请求的响应 store 为 nil 时使用 MemoryCacheStore
+// 缓存在中间件的最外层 命中时不会执行其他中间件
+func WithCache(store CacheStore, opts ...CacheOption) ClientOption {
+	return func(client *Client) {
+		client.middlewares = append([]Middleware{CacheMiddleware(store, opts...)}, client.middlewares...)
+	}
+}
+
+type CacheOption func(*httpCache)
+
+// WithCacheShared 作为共享缓存 private 的响应不缓存 优先使用 s-maxage
+// 多个进程或用户共用 store 时使用 RedisCacheStore 默认为共享缓存
+func WithCacheShared(shared bool) CacheOption {
+	return func(c *httpCache) {
+		c.shared = shared
+	}
+}
+
+// WithCacheMaxBodySize body 超过 n 字节的响应不缓存 默认10MB <=0 不限制
+func WithCacheMaxBodySize(n int64) CacheOption {
+	return func(c *httpCache) {
+		c.maxBodySize = n
+	}
+}
+
+// CacheMiddleware 按 RFC 7234 缓存响应的中间件 默认作为私有缓存 private 的响应也会缓存
+// 带 Authorization 或 Cookie 的请求只在响应有 public、s-maxage 或 must-revalidate 时缓存
+// 支持 Cache-Control、Expires、ETag 和 Last-Modified 过期后带 If-None-Match 和 If-Modified-Since 验证
+// 支持 stale-while-revalidate 和 stale-if-error 其他方法的请求成功后删除对应url的缓存
+// 带 Range 或 If-Range 的请求不使用缓存 206 响应不缓存
+func CacheMiddleware(store CacheStore, opts ...CacheOption) Middleware {
+	return newHTTPCache(store, opts...).middleware
+}
+
+type httpCache struct {
+	store       CacheStore
+	now         func() time.Time
+	maxBodySize int64
+	shared      bool
+
+	mu           sync.Mutex
+	revalidating map[string]bool
+}
+
+func newHTTPCache(store CacheStore, opts ...CacheOption) *httpCache {
+	if store == nil {
+		store = NewMemoryCacheStore(0)
+	}
+	_, shared := store.(*RedisCacheStore)
+	c := &httpCache{
+		store:        store,
+		now:          time.Now,
+		maxBodySize:  defaultCacheMaxBodySize,
+		shared:       shared,
+		revalidating: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// cacheEntry 缓存的响应 Body 为未解码的原始内容
+type cacheEntry struct {
+	StatusCode   int
+	Header       http.Header
+	Body         []byte
+	Vary         http.Header // Vary 中的请求头 请求头不同时不使用缓存
+	RequestTime  time.Time
+	ResponseTime time.Time
+}
+
+func (c *httpCache) middleware(next Handler) Handler {
+	return func(req *http.Request) (*Response, error) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			resp, err := next(req)
+			if err == nil && !isSafeMethod(req.Method) && resp.StatusCode() < http.StatusBadRequest {
+				c.invalidate(req)
+			}
+			return resp, err
+		}
+
+		reqCC := parseCacheControl(req.Header)
+		if reqCC.has("no-store") || req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+			// 调用方自己验证缓存
+			return next(req)
+		}
+		if req.Header.Get("Range") != "" || req.Header.Get("If-Range") != "" {
+			// 不缓存部分内容
+			return next(req)
+		}
+
+		key := cacheKey(req)
+		entry := c.load(req.Context(), key, req)
+		if entry == nil {
+			if reqCC.has("only-if-cached") {
+				return newCachedStatusResponse(req, http.StatusGatewayTimeout), nil
+			}
+			return c.fetch(req, next, key)
+		}
+
+		now := c.now()
+		respCC := parseCacheControl(entry.Header)
+		age := entry.age(now)
+		lifetime := entry.freshnessLifetime(respCC, c.shared)
+		staleness := age - lifetime
+
+		if reqCC.has("only-if-cached") || c.fresh(reqCC, respCC, age, lifetime) {
+			return entry.response(req, age), nil
+		}
+
+		// 过期但在 stale-while-revalidate 的时间内 直接返回并在后台验证
+		if !reqCC.has("no-cache") && !respCC.has("no-cache") && !mustRevalidate(respCC) {
+			if swr, ok := respCC.seconds("stale-while-revalidate"); ok && staleness <= swr {
+				resp := entry.response(req, age)
+				c.revalidateInBackground(req, next, key, entry)
+				return resp, nil
+			}
+		}
+
+		resp, err := c.validate(req, next, key, entry)
+		if staleIfError(reqCC, respCC, staleness, resp, err) {
+			resp.discard()
+			return entry.response(req, age), nil
+		}
+		return resp, err
+	}
+}
+
+// fresh 按请求的 max-age、min-fresh、max-stale 和响应的 no-cache 判断是否可以直接使用
+func (c *httpCache) fresh(reqCC, respCC cacheControl, age, lifetime time.Duration) bool {
+	if reqCC.has("no-cache") || respCC.has("no-cache") {
+		return false
+	}
+	if maxAge, ok := reqCC.seconds("max-age"); ok && age > maxAge {
+		return false
+	}
+	if minFresh, ok := reqCC.seconds("min-fresh"); ok {
+		age += minFresh
+	}
+	if age < lifetime {
+		return true
+	}
+	if reqCC.has("max-stale") && !mustRevalidate(respCC) {
+		maxStale, ok := reqCC.seconds("max-stale")
+		return !ok || age-lifetime <= maxStale
+	}
+	return false
+}
+
+// fetch 没有缓存时发送请求 可以缓存时保存响应
+func (c *httpCache) fetch(req *http.Request, next Handler, key string) (*Response, error) {
+	requestTime := c.now()
+	resp, err := next(req)
+	if err != nil {
+		return resp, err
+	}
+	c.save(req, key, resp, requestTime)
+	return resp, nil
+}
+
+// validate 带上 If-None-Match 和 If-Modified-Since 验证缓存 304 时更新缓存的头部
+func (c *httpCache) validate(req *http.Request, next Handler, key string, entry *cacheEntry) (*Response, error) {
+	condReq := req.Clone(req.Context())
+	if etag := entry.Header.Get("ETag"); etag != "" {
+		condReq.Header.Set("If-None-Match", etag)
+	}
+	if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+		condReq.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	requestTime := c.now()
+	resp, err := next(condReq)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode() != http.StatusNotModified {
+		c.save(req, key, resp, requestTime)
+		return resp, nil
+	}
+
+	resp.discard()
+	for name, values := range resp.Headers() {
+		if !strings.EqualFold(name, "Content-Length") {
+			entry.Header[name] = values
+		}
+	}
+	entry.RequestTime, entry.ResponseTime = requestTime, c.now()
+	c.put(req.Context(), key, entry)
+	return entry.response(req, entry.age(c.now())), nil
+}
+
+func (c *httpCache) revalidateInBackground(req *http.Request, next Handler, key string, entry *cacheEntry) {
+	c.mu.Lock()
+	if c.revalidating[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.revalidating[key] = true
+	c.mu.Unlock()
+
+	bgReq := req.Clone(context.WithoutCancel(req.Context()))
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.revalidating, key)
+			c.mu.Unlock()
+		}()
+
+		resp, err := c.validate(bgReq, next, key, entry)
+		if err == nil {
+			resp.discard()
+		}
+	}()
+}
+
+// save 可以缓存时读取 body 并保存 body 替换为内存中的副本 调用方可以正常读取
+// body 超过 maxBodySize 时不缓存 已经读取的部分放回 body
+// 读取失败时不缓存 调用方读完已经读取的部分后返回同样的错误
+func (c *httpCache) save(req *http.Request, key string, resp *Response, requestTime time.Time) {
+	if resp == nil || resp.resp == nil || !c.storable(req, resp) {
+		return
+	}
+
+	var vary http.Header
+	for _, field := range strings.Split(resp.resp.Header.Get("Vary"), ",") {
+		field = http.CanonicalHeaderKey(strings.TrimSpace(field))
+		if field == "*" {
+			return
+		}
+		if field != "" {
+			if vary == nil {
+				vary = make(http.Header)
+			}
+			vary[field] = req.Header.Values(field)
+		}
+	}
+
+	if c.maxBodySize > 0 && resp.resp.ContentLength > c.maxBodySize {
+		return
+	}
+
+	var body []byte
+	if resp.resp.Body != nil {
+		reader := io.Reader(resp.resp.Body)
+		if c.maxBodySize > 0 {
+			reader = io.LimitReader(reader, c.maxBodySize+1)
+		}
+		var err error
+		body, err = io.ReadAll(reader)
+		if err != nil {
+			resp.resp.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), errReader{err}), resp.resp.Body}
+			return
+		}
+		if c.maxBodySize > 0 && int64(len(body)) > c.maxBodySize {
+			resp.resp.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), resp.resp.Body), resp.resp.Body}
+			return
+		}
+		resp.resp.Body.Close()
+		resp.resp.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	c.put(req.Context(), key, &cacheEntry{
+		StatusCode:   resp.resp.StatusCode,
+		Header:       resp.resp.Header.Clone(),
+		Body:         body,
+		Vary:         vary,
+		RequestTime:  requestTime,
+		ResponseTime: c.now(),
+	})
+}
+
+// storable 响应可以缓存 并且有过期时间或者可以验证
+// 缓存的 key 不区分用户 带认证信息的请求按 RFC 7234 3.2 处理
+func (c *httpCache) storable(req *http.Request, resp *Response) bool {
+	header := resp.resp.Header
+	cc := parseCacheControl(header)
+	if cc.has("no-store") || resp.resp.StatusCode == http.StatusPartialContent ||
+		strings.HasPrefix(header.Get("Content-Type"), "text/event-stream") {
+		return false
+	}
+	if c.shared && cc.has("private") {
+		return false
+	}
+	_, hasSMaxAge := cc.seconds("s-maxage")
+	if req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != "" {
+		if !cc.has("public") && !hasSMaxAge && !cc.has("must-revalidate") {
+			return false
+		}
+	}
+
+	_, hasMaxAge := cc.seconds("max-age")
+	explicit := hasMaxAge || header.Get("Expires") != "" || cc.has("public") || (c.shared && hasSMaxAge)
+	if !cacheableStatusCodes[resp.resp.StatusCode] && !explicit {
+		return false
+	}
+	return explicit || header.Get("ETag") != "" || header.Get("Last-Modified") != ""
+}
+
+func (c *httpCache) invalidate(req *http.Request) {
+	url := req.URL.String()
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		_ = c.store.Delete(req.Context(), method+" "+url)
+	}
+}
+
+// load 读取缓存 Vary 的请求头不同时返回 nil
+func (c *httpCache) load(ctx context.Context, key string, req *http.Request) *cacheEntry {
+	data, ok, err := c.store.Get(ctx, key)
+	if err != nil || !ok {
+		return nil
+	}
+
+	var entry cacheEntry
+	if err = json.Unmarshal(data, &entry); err != nil {
+		return nil
+	}
+	for field, values := range entry.Vary {
+		if strings.Join(values, ",") != strings.Join(req.Header.Values(field), ",") {
+			return nil
+		}
+	}
+	return &entry
+}
+
+func (c *httpCache) put(ctx context.Context, key string, entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err == nil {
+		_ = c.store.Set(ctx, key, data)
+	}
+}
+
+// errReader 读取时返回 err
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func cacheKey(req *http.Request) string {
+	return req.Method + " " + req.URL.String()
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func mustRevalidate(cc cacheControl) bool {
+	return cc.has("must-revalidate") || cc.has("proxy-revalidate")
+}
+
+// staleIfError 验证失败或者返回 5xx 时在 stale-if-error 的时间内可以使用过期的缓存
+func staleIfError(reqCC, respCC cacheControl, staleness time.Duration, resp *Response, err error) bool {
+	if err == nil && resp.StatusCode() < http.StatusInternalServerError {
+		return false
+	}
+	if mustRevalidate(respCC) {
+		return false
+	}
+	for _, cc := range []cacheControl{reqCC, respCC} {
+		if sie, ok := cc.seconds("stale-if-error"); ok && staleness <= sie {
+			return true
+		}
+	}
+	return false
+}
+
+// age RFC 7234 4.2.3
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	date := e.ResponseTime
+	if t, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		date = t
+	}
+	apparentAge := max(0, e.ResponseTime.Sub(date))
+
+	var ageValue time.Duration
+	if seconds, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil && seconds > 0 {
+		ageValue = time.Duration(seconds) * time.Second
+	}
+	correctedAge := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	return max(apparentAge, correctedAge) + now.Sub(e.ResponseTime)
+}
+
+// freshnessLifetime RFC 7234 4.2.1 没有明确的过期时间时按 Last-Modified 的10%估算
+func (e *cacheEntry) freshnessLifetime(cc cacheControl, shared bool) time.Duration {
+	if sMaxAge, ok := cc.seconds("s-maxage"); ok && shared {
+		return sMaxAge
+	}
+	if maxAge, ok := cc.seconds("max-age"); ok {
+		return maxAge
+	}
+
+	date := e.ResponseTime
+	if t, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		date = t
+	}
+	if expires := e.Header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		return t.Sub(date)
+	}
+
+	if lastModified, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil && cacheableStatusCodes[e.StatusCode] {
+		return max(0, date.Sub(lastModified)/10)
+	}
+	return 0
+}
+
+func (e *cacheEntry) response(req *http.Request, age time.Duration) *Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+
+	body := io.NopCloser(bytes.NewReader(e.Body))
+	if req.Method == http.MethodHead {
+		body = http.NoBody
+	}
+	return &Response{
+		resp: &http.Response{
+			Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+			StatusCode:    e.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          body,
+			ContentLength: int64(len(e.Body)),
+			Request:       req,
+		},
+		fromCache: true,
+	}
+}
+
+func newCachedStatusResponse(req *http.Request, statusCode int) *Response {
+	entry := &cacheEntry{StatusCode: statusCode, Header: make(http.Header)}
+	return entry.response(req, 0)
+}
+
+// cacheControl Cache-Control 的指令 key 为小写
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := make(cacheControl)
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				cc[name] = strings.Trim(strings.TrimSpace(arg), `"`)
+			}
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// seconds 指令的秒数 没有这个指令或者不是数字时返回 false
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	value, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
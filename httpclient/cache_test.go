@@ -0,0 +1,352 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func newCacheClient(store CacheStore, opts ...CacheOption) (*Client, *fakeClock) {
+	clock := &fakeClock{now: time.Now()}
+	cache := newHTTPCache(store, opts...)
+	cache.now = clock.Now
+	return NewClient(WithMiddleware(cache.middleware)), clock
+}
+
+func cacheGet(t *testing.T, client *Client, url string, heads ...string) (string, bool) {
+	t.Helper()
+	req := client.NewRequest(http.MethodGet, url)
+	for i := 0; i+1 < len(heads); i += 2 {
+		req.SetHead(heads[i], heads[i+1])
+	}
+	resp, err := req.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := resp.Body()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body), resp.FromCache()
+}
+
+func TestCache_Validation(t *testing.T) {
+	var hits, conditional atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header()["Date"] = nil // 时钟是模拟的 不使用服务端的 Date
+		switch r.URL.Path {
+		case "/etag":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				conditional.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/last-modified":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+			if r.Header.Get("If-Modified-Since") != "" {
+				conditional.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer s.Close()
+
+	client, clock := newCacheClient(nil)
+	tests := []struct {
+		path      string
+		advance   time.Duration
+		fromCache bool
+		hits      int32
+		cond      int32
+	}{
+		{"/etag", 0, false, 1, 0},
+		{"/etag", 30 * time.Second, true, 1, 0},
+		{"/etag", 31 * time.Second, true, 2, 1}, // 过期 验证后使用缓存
+		{"/etag", 0, true, 2, 1},                // 304 后重新计算过期时间
+		{"/last-modified", 0, false, 3, 1},
+		{"/last-modified", 0, true, 4, 2}, // no-cache 每次都验证
+		{"/no-store", 0, false, 5, 2},
+		{"/no-store", 0, false, 6, 2},
+	}
+	for i, tt := range tests {
+		clock.Add(tt.advance)
+		body, fromCache := cacheGet(t, client, s.URL+tt.path)
+		if body != tt.path || fromCache != tt.fromCache || hits.Load() != tt.hits || conditional.Load() != tt.cond {
+			t.Fatalf("%d: body = %q, from cache = %v, hits = %d, conditional = %d", i, body, fromCache, hits.Load(), conditional.Load())
+		}
+	}
+
+	// 请求 no-cache 强制验证
+	if _, fromCache := cacheGet(t, client, s.URL+"/etag", "Cache-Control", "no-cache"); !fromCache || conditional.Load() != 3 {
+		t.Fatalf("from cache = %v, conditional = %d", fromCache, conditional.Load())
+	}
+
+	// 其他方法的请求删除缓存
+	if _, err := client.NewRequest(http.MethodPost, s.URL+"/etag").Do(); err != nil {
+		t.Fatal(err)
+	}
+	if _, fromCache := cacheGet(t, client, s.URL+"/etag"); fromCache {
+		t.Fatal("cache should be invalidated by POST")
+	}
+}
+
+func TestCache_Stale(t *testing.T) {
+	var hits atomic.Int32
+	var fail atomic.Bool
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		w.Header()["Date"] = nil
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=10, stale-while-revalidate=30, stale-if-error=300")
+		fmt.Fprintf(w, "v%d", n)
+	}))
+	defer s.Close()
+
+	client, clock := newCacheClient(NewMemoryCacheStore(10))
+	if body, _ := cacheGet(t, client, s.URL); body != "v1" {
+		t.Fatalf("body = %q", body)
+	}
+
+	// stale-while-revalidate 直接返回过期的缓存 在后台更新
+	clock.Add(20 * time.Second)
+	if body, fromCache := cacheGet(t, client, s.URL); body != "v1" || !fromCache {
+		t.Fatalf("body = %q, from cache = %v", body, fromCache)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if body, _ := cacheGet(t, client, s.URL); body == "v2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background revalidation did not update the cache")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 超过 stale-while-revalidate 验证失败时使用 stale-if-error
+	fail.Store(true)
+	clock.Add(60 * time.Second)
+	if body, fromCache := cacheGet(t, client, s.URL); body != "v2" || !fromCache {
+		t.Fatalf("body = %q, from cache = %v", body, fromCache)
+	}
+	clock.Add(time.Hour)
+	resp, err := client.NewRequest(http.MethodGet, s.URL).Do()
+	if err != nil || resp.StatusCode() != http.StatusInternalServerError {
+		t.Fatalf("status = %d, err = %v", resp.StatusCode(), err)
+	}
+}
+
+func TestCache_Vary(t *testing.T) {
+	var hits atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		fmt.Fprint(w, r.Header.Get("Accept-Language"))
+	}))
+	defer s.Close()
+
+	client, _ := newCacheClient(nil)
+	for i, lang := range []string{"en", "en", "zh", "zh"} {
+		body, fromCache := cacheGet(t, client, s.URL, "Accept-Language", lang)
+		if body != lang || fromCache != (i%2 == 1) {
+			t.Fatalf("%d: body = %q, from cache = %v", i, body, fromCache)
+		}
+	}
+
+	resp, _ := client.NewRequest(http.MethodGet, s.URL+"/missing").SetHead("Cache-Control", "only-if-cached").Do()
+	if resp.StatusCode() != http.StatusGatewayTimeout || hits.Load() != 2 {
+		t.Fatalf("status = %d, hits = %d", resp.StatusCode(), hits.Load())
+	}
+}
+
+func TestCache_RangeAndSize(t *testing.T) {
+	var hits atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/big" {
+			// 分块发送 没有 Content-Length
+			fmt.Fprint(w, "01234")
+			w.(http.Flusher).Flush()
+			fmt.Fprint(w, "56789")
+			return
+		}
+		if r.Header.Get("Range") != "" {
+			w.WriteHeader(http.StatusPartialContent)
+			fmt.Fprint(w, "part")
+			return
+		}
+		fmt.Fprint(w, "full")
+	}))
+	defer s.Close()
+
+	client, _ := newCacheClient(nil, WithCacheMaxBodySize(8))
+
+	// Range 请求不使用缓存 206 不缓存
+	for i := 0; i < 2; i++ {
+		if body, fromCache := cacheGet(t, client, s.URL, "Range", "bytes=0-3"); body != "part" || fromCache {
+			t.Fatalf("range %d: body = %q, from cache = %v", i, body, fromCache)
+		}
+	}
+	if body, fromCache := cacheGet(t, client, s.URL); body != "full" || fromCache || hits.Load() != 3 {
+		t.Fatalf("body = %q, from cache = %v, hits = %d", body, fromCache, hits.Load())
+	}
+	if body, fromCache := cacheGet(t, client, s.URL, "If-Range", `"v1"`); body != "full" || fromCache || hits.Load() != 4 {
+		t.Fatalf("if-range: body = %q, from cache = %v, hits = %d", body, fromCache, hits.Load())
+	}
+
+	// 超过大小限制的 body 不缓存 调用方仍然读到完整的 body
+	for i := 0; i < 2; i++ {
+		if body, fromCache := cacheGet(t, client, s.URL+"/big"); body != "0123456789" || fromCache {
+			t.Fatalf("big %d: body = %q, from cache = %v", i, body, fromCache)
+		}
+	}
+	if hits.Load() != 6 {
+		t.Fatalf("hits = %d, want 6", hits.Load())
+	}
+}
+
+func TestCache_ShortBody(t *testing.T) {
+	var hits atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Content-Length", "10")
+		fmt.Fprint(w, "01234")
+		w.(http.Flusher).Flush()
+		// body 没有发送完就断开连接
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer s.Close()
+
+	client, _ := newCacheClient(nil)
+	for i := 0; i < 2; i++ {
+		resp, err := client.NewRequest(http.MethodGet, s.URL).Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, err := resp.Body(); err == nil || resp.FromCache() {
+			t.Fatalf("request %d: body = %q, err = %v, from cache = %v", i, body, err, resp.FromCache())
+		}
+	}
+	if hits.Load() != 2 {
+		t.Fatalf("hits = %d, want 2", hits.Load())
+	}
+}
+
+func TestCache_AuthAndShared(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/public":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/shared":
+			w.Header().Set("Cache-Control", "max-age=0, s-maxage=60")
+		default:
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer s.Close()
+
+	for _, tc := range []struct {
+		shared    bool
+		path      string
+		heads     []string
+		fromCache bool
+	}{
+		{false, "/auth", []string{"Authorization", "Bearer a"}, false},
+		{false, "/cookie", []string{"Cookie", "session=a"}, false},
+		{false, "/public", []string{"Authorization", "Bearer a"}, true},
+		{false, "/private", nil, true},
+		{false, "/shared", nil, false},
+		{true, "/private", nil, false},
+		{true, "/shared", []string{"Authorization", "Bearer a"}, true},
+	} {
+		client, _ := newCacheClient(nil, WithCacheShared(tc.shared))
+		for i := 0; i < 2; i++ {
+			body, fromCache := cacheGet(t, client, s.URL+tc.path, tc.heads...)
+			if body != tc.path || fromCache != (i == 1 && tc.fromCache) {
+				t.Fatalf("shared = %v, %s %d: body = %q, from cache = %v", tc.shared, tc.path, i, body, fromCache)
+			}
+		}
+	}
+}
+
+func TestCacheStores(t *testing.T) {
+	ctx := context.Background()
+	stores := map[string]CacheStore{
+		"memory": NewMemoryCacheStore(2),
+		"disk":   NewDiskCacheStore(t.TempDir()),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			if _, ok, err := store.Get(ctx, "a"); ok || err != nil {
+				t.Fatalf("ok = %v, err = %v", ok, err)
+			}
+			for _, key := range []string{"a", "b", "a"} {
+				if err := store.Set(ctx, key, []byte(key+"1")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if value, ok, _ := store.Get(ctx, "a"); !ok || string(value) != "a1" {
+				t.Fatalf("value = %q, ok = %v", value, ok)
+			}
+			if err := store.Delete(ctx, "a"); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok, _ := store.Get(ctx, "a"); ok {
+				t.Fatal("a should be deleted")
+			}
+			if err := store.Delete(ctx, "a"); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	memory := NewMemoryCacheStore(2)
+	for _, key := range []string{"a", "b", "c"} {
+		_ = memory.Set(ctx, key, []byte(key))
+	}
+	if _, ok, _ := memory.Get(ctx, "a"); ok || memory.Len() != 2 {
+		t.Fatalf("least recently used entry should be evicted, len = %d", memory.Len())
+	}
+}
@@ -0,0 +1,186 @@
+package httpclient
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CacheStore 缓存响应的存储 value 为编码后的响应
+type CacheStore interface {
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte) error
+	Delete(ctx context.Context, key string) error
+}
+
+const defaultMemoryCacheEntries = 1024
+
+// MemoryCacheStore 内存存储 超过数量时淘汰最久未使用的
+type MemoryCacheStore struct {
+	maxEntries int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type memoryCacheEntry struct {
+	key   string
+	value []byte
+}
+
+// NewMemoryCacheStore maxEntries <=0 时为1024
+func NewMemoryCacheStore(maxEntries int) *MemoryCacheStore {
+	if maxEntries <= 0 {
+		maxEntries = defaultMemoryCacheEntries
+	}
+	return &MemoryCacheStore{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (s *MemoryCacheStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	s.ll.MoveToFront(elem)
+	return elem.Value.(*memoryCacheEntry).value, true, nil
+}
+
+func (s *MemoryCacheStore) Set(ctx context.Context, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.items[key]; ok {
+		elem.Value.(*memoryCacheEntry).value = value
+		s.ll.MoveToFront(elem)
+		return nil
+	}
+
+	s.items[key] = s.ll.PushFront(&memoryCacheEntry{key: key, value: value})
+	for s.ll.Len() > s.maxEntries {
+		entry := s.ll.Remove(s.ll.Back()).(*memoryCacheEntry)
+		delete(s.items, entry.key)
+	}
+	return nil
+}
+
+func (s *MemoryCacheStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.items[key]; ok {
+		s.ll.Remove(elem)
+		delete(s.items, key)
+	}
+	return nil
+}
+
+// Len 缓存的响应数
+func (s *MemoryCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
+
+// DiskCacheStore 每个响应保存为目录下的一个文件 文件名为 key 的 sha256 写入时先写临时文件再重命名
+type DiskCacheStore struct {
+	Dir string
+}
+
+func NewDiskCacheStore(dir string) *DiskCacheStore {
+	return &DiskCacheStore{Dir: dir}
+}
+
+func (s *DiskCacheStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:]))
+}
+
+func (s *DiskCacheStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func (s *DiskCacheStore) Set(ctx context.Context, key string, value []byte) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+
+	path := s.path(key)
+	tmp, err := os.CreateTemp(s.Dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(value); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *DiskCacheStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// RedisCacheStore 保存到redis 多个进程共享缓存 作为共享缓存 private 的响应不缓存
+type RedisCacheStore struct {
+	client     redis.UniversalClient
+	prefix     string
+	expiration time.Duration
+}
+
+// NewRedisCacheStore key 为 prefix 加上请求的 key expiration 为0时不过期
+func NewRedisCacheStore(client redis.UniversalClient, prefix string, expiration time.Duration) *RedisCacheStore {
+	return &RedisCacheStore{client: client, prefix: prefix, expiration: expiration}
+}
+
+func (s *RedisCacheStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func (s *RedisCacheStore) Set(ctx context.Context, key string, value []byte) error {
+	return s.client.Set(ctx, s.prefix+key, value, s.expiration).Err()
+}
+
+func (s *RedisCacheStore) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
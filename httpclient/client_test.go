@@ -3,8 +3,11 @@ package httpclient
 import (
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"net/http/httputil"
 	"testing"
+
+	"github.com/windzhu0514/go-utils/httpclient/cookiejar"
 )
 
 func TestGet(t *testing.T) {
@@ -46,3 +49,36 @@ func TestSetCookie(t *testing.T) {
 		dumplicatMap[cc.Name] = true
 	}
 }
+
+func TestClient_AllCookies(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "abc"})
+	}))
+	defer s.Close()
+
+	jar, err := cookiejar.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient(WithJar(jar))
+	if _, err = client.NewRequest(http.MethodGet, s.URL).Do(); err != nil {
+		t.Fatal(err)
+	}
+
+	cookies, err := client.AllCookies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 1 || cookies[0].Name != "sid" || cookies[0].Value != "abc" {
+		t.Fatalf("AllCookies() = %+v", cookies)
+	}
+
+	client.SetCookie(&http.Cookie{Name: "a", Value: "1"})
+	client.SetCookie(&http.Cookie{Name: "b", Value: "1"})
+	client.DelCookie(&http.Cookie{Name: "a"})
+	client.DelCookie(&http.Cookie{Name: "b"})
+	if len(client.cookies) != 0 {
+		t.Fatalf("cookies = %d, want 0", len(client.cookies))
+	}
+}
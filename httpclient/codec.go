@@ -0,0 +1,191 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// Codec 请求body编码和返回结果解码
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = make(map[string]Codec)
+)
+
+func init() {
+	RegisterCodec(MIMEJSON, jsonCodec{})
+	RegisterCodec(MIMEXML, xmlCodec{})
+	RegisterCodec(MIMETextXML, xmlCodec{})
+	RegisterCodec(MIMEYAML, yamlCodec{})
+	RegisterCodec("application/yaml", yamlCodec{})
+	RegisterCodec("text/yaml", yamlCodec{})
+	RegisterCodec(MIMEXPROTOBUF, protoCodec{})
+	RegisterCodec("application/protobuf", protoCodec{})
+	RegisterCodec(MIMEPOSTForm, formCodec{})
+	RegisterCodec(MIMEXMSGPACK, msgpackCodec{})
+	RegisterCodec(MIMEMSGPACK, msgpackCodec{})
+}
+
+// RegisterCodec 注册 Content-Type 对应的编解码器 已存在时覆盖
+func RegisterCodec(contentType string, codec Codec) {
+	codecsMu.Lock()
+	codecs[normalizeContentType(contentType)] = codec
+	codecsMu.Unlock()
+}
+
+// GetCodec 获取 Content-Type 对应的编解码器 忽略参数部分
+// 没有精确匹配时 +json 和 +xml 后缀分别使用 json 和 xml 编解码器
+func GetCodec(contentType string) Codec {
+	mediaType := normalizeContentType(contentType)
+
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	if codec, ok := codecs[mediaType]; ok {
+		return codec
+	}
+
+	switch {
+	case strings.HasSuffix(mediaType, "+json"):
+		return codecs[MIMEJSON]
+	case strings.HasSuffix(mediaType, "+xml"):
+		return codecs[MIMEXML]
+	}
+
+	return nil
+}
+
+func normalizeContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	}
+	return strings.ToLower(mediaType)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+type yamlCodec struct{}
+
+func (yamlCodec) Marshal(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+func (yamlCodec) Unmarshal(data []byte, v interface{}) error {
+	return yaml.Unmarshal(data, v)
+}
+
+type protoCodec struct{}
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("httpclient: %T is not proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("httpclient: %T is not proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// msgpackCodec 字段没有 msgpack tag 时使用 json tag
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// formCodec application/x-www-form-urlencoded
+// 支持 url.Values、map[string]string 和 map[string][]string
+type formCodec struct{}
+
+func (formCodec) Marshal(v interface{}) ([]byte, error) {
+	switch values := v.(type) {
+	case url.Values:
+		return []byte(values.Encode()), nil
+	case map[string][]string:
+		return []byte(url.Values(values).Encode()), nil
+	case map[string]string:
+		form := make(url.Values, len(values))
+		for k, v := range values {
+			form.Set(k, v)
+		}
+		return []byte(form.Encode()), nil
+	default:
+		return nil, fmt.Errorf("httpclient: form codec unsupported type %T", v)
+	}
+}
+
+func (formCodec) Unmarshal(data []byte, v interface{}) error {
+	form, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+
+	switch values := v.(type) {
+	case *url.Values:
+		*values = form
+	case *map[string][]string:
+		*values = form
+	case *map[string]string:
+		if *values == nil {
+			*values = make(map[string]string, len(form))
+		}
+		for k := range form {
+			(*values)[k] = form.Get(k)
+		}
+	default:
+		return fmt.Errorf("httpclient: form codec unsupported type %T", v)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package httpclient
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type codecUser struct {
+	XMLName xml.Name `xml:"user" json:"-" yaml:"-"`
+	Name    string   `xml:"name" json:"name" yaml:"name"`
+}
+
+func TestGetCodec(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        Codec
+	}{
+		{MIMEJSON, jsonCodec{}},
+		{"application/json; charset=utf-8", jsonCodec{}},
+		{"application/vnd.api+json", jsonCodec{}},
+		{"Text/XML", xmlCodec{}},
+		{"application/atom+xml", xmlCodec{}},
+		{MIMEYAML, yamlCodec{}},
+		{MIMEXPROTOBUF, protoCodec{}},
+		{MIMEPOSTForm, formCodec{}},
+		{MIMEXMSGPACK, msgpackCodec{}},
+		{MIMEMSGPACK, msgpackCodec{}},
+		{"application/x-unknown", nil},
+	}
+	for _, tt := range tests {
+		if got := GetCodec(tt.contentType); got != tt.want {
+			t.Errorf("GetCodec(%q) = %T, want %T", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestRequest_SetBodyCodec(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		_, _ = io.Copy(w, r.Body)
+	}))
+	defer s.Close()
+
+	client := NewClient()
+	for _, contentType := range []string{MIMEJSON, MIMEXML, MIMEYAML, MIMEXMSGPACK} {
+		req := client.NewRequest(http.MethodPost, s.URL).SetBody(contentType, codecUser{Name: "Ava"})
+		resp, err := req.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var user codecUser
+		if err := resp.Decode(&user); err != nil {
+			t.Fatalf("%s: %v", contentType, err)
+		}
+		if user.Name != "Ava" {
+			t.Fatalf("%s: name = %q, want Ava", contentType, user.Name)
+		}
+	}
+
+	resp, err := client.NewRequest(http.MethodPost, s.URL).SetBody(MIMEXPROTOBUF, wrapperspb.String("Ava")).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg wrapperspb.StringValue
+	if err := resp.Decode(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.GetValue() != "Ava" {
+		t.Fatalf("proto value = %q, want Ava", msg.GetValue())
+	}
+
+	// msgpack 不能回退为 json
+	resp, err = client.NewRequest(http.MethodPost, s.URL).SetBody(MIMEMSGPACK, codecUser{Name: "Ava"}).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := resp.Body(); len(body) == 0 || body[0] == '{' {
+		t.Fatalf("msgpack body = %q", body)
+	}
+
+	resp, err = client.NewRequest(http.MethodPost, s.URL).SetBody(MIMEPOSTForm, map[string]string{"name": "Ava"}).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var form url.Values
+	if err := resp.Decode(&form); err != nil {
+		t.Fatal(err)
+	}
+	if form.Get("name") != "Ava" {
+		t.Fatalf("form name = %q, want Ava", form.Get("name"))
+	}
+}
+
+type upperCodec struct{}
+
+func (upperCodec) Marshal(v interface{}) ([]byte, error) {
+	return []byte(strings.ToUpper(v.(codecUser).Name)), nil
+}
+
+func (upperCodec) Unmarshal(data []byte, v interface{}) error {
+	v.(*codecUser).Name = strings.ToLower(string(data))
+	return nil
+}
+
+func TestRegisterCodec(t *testing.T) {
+	RegisterCodec("application/x-upper", upperCodec{})
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-upper")
+		_, _ = io.Copy(w, r.Body)
+	}))
+	defer s.Close()
+
+	resp, err := NewClient().NewRequest(http.MethodPost, s.URL).SetBody("application/x-upper", codecUser{Name: "Ava"}).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var user codecUser
+	if err := resp.Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "ava" {
+		t.Fatalf("name = %q, want ava", user.Name)
+	}
+}
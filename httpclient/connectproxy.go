@@ -79,6 +79,7 @@ package httpclient
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -219,11 +220,33 @@ func GeneratorWithConfig(config *Config) func(*url.URL, proxy.Dialer) (proxy.Dia
 
 // Dial connects to the given address via the server.
 func (cd *connectDialer) Dial(network, addr string) (net.Conn, error) {
+	return cd.DialContext(context.Background(), network, addr)
+}
+
+// DialContext is like Dial, but closes the connection to the proxy server
+// as soon as ctx is done.
+func (cd *connectDialer) DialContext(ctx context.Context, network, addr string) (conn net.Conn, err error) {
 	/* Connect to proxy server */
-	nc, err := cd.forward.Dial("tcp", cd.u.Host)
+	var nc net.Conn
+	if fd, ok := cd.forward.(proxy.ContextDialer); ok {
+		nc, err = fd.DialContext(ctx, "tcp", cd.u.Host)
+	} else {
+		nc, err = cd.forward.Dial("tcp", cd.u.Host)
+	}
 	if nil != err {
 		return nil, err
 	}
+
+	/* Abort the CONNECT handshake if ctx is done */
+	stop := context.AfterFunc(ctx, func() { nc.Close() })
+	defer func() {
+		if !stop() && nil == err {
+			nc.Close()
+			conn, err = nil, ctx.Err()
+		} else if nil != err && nil != ctx.Err() {
+			err = ctx.Err()
+		}
+	}()
 	/* Upgrade to TLS if necessary */
 	if "https" == cd.u.Scheme {
 		nc = tls.Client(nc, &tls.Config{
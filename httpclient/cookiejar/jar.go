@@ -0,0 +1,409 @@
+// Package cookiejar 可以列出、导出和持久化的 http.CookieJar
+package cookiejar
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Cookie jar中保存的cookie 可以序列化
+type Cookie struct {
+	Name     string        `json:"name"`
+	Value    string        `json:"value"`
+	Domain   string        `json:"domain"`
+	Path     string        `json:"path"`
+	Expires  time.Time     `json:"expires,omitempty"` // 零值表示会话cookie
+	Secure   bool          `json:"secure,omitempty"`
+	HttpOnly bool          `json:"httpOnly,omitempty"`
+	SameSite http.SameSite `json:"sameSite,omitempty"`
+	HostOnly bool          `json:"hostOnly,omitempty"` // 只发送给 Domain 本身 不包括子域名
+	Creation time.Time     `json:"creation"`
+}
+
+func (c *Cookie) id() string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+func (c *Cookie) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+// HTTPCookie 转换为 http.Cookie
+func (c *Cookie) HTTPCookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   c.Domain,
+		Path:     c.Path,
+		Expires:  c.Expires,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		SameSite: c.SameSite,
+	}
+}
+
+type Option func(*Jar)
+
+// WithStorage 设置持久化存储
+func WithStorage(storage Storage) Option {
+	return func(j *Jar) {
+		j.storage = storage
+	}
+}
+
+// WithAutoSave 每次 SetCookies 后自动保存到存储
+func WithAutoSave(autoSave bool) Option {
+	return func(j *Jar) {
+		j.autoSave = autoSave
+	}
+}
+
+// Jar 并发安全的 http.CookieJar
+// 使用 publicsuffix 列表拒绝设置在公共后缀上的cookie
+type Jar struct {
+	mu      sync.Mutex
+	entries map[string]*Cookie
+
+	storage  Storage
+	autoSave bool
+}
+
+// New 创建 Jar 设置了存储时从存储加载cookie
+func New(opts ...Option) (*Jar, error) {
+	j := &Jar{entries: make(map[string]*Cookie)}
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	if j.storage != nil {
+		if err := j.Load(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+
+	return j, nil
+}
+
+// Load 从存储加载cookie 与已有cookie合并
+func (j *Jar) Load(ctx context.Context) error {
+	if j.storage == nil {
+		return nil
+	}
+
+	cookies, err := j.storage.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	j.Add(cookies...)
+	return nil
+}
+
+// Save 保存未过期的cookie到存储 会话cookie也会保存
+func (j *Jar) Save(ctx context.Context) error {
+	if j.storage == nil {
+		return nil
+	}
+
+	return j.storage.Save(ctx, j.All())
+}
+
+// Add 直接添加cookie 用于导入 Domain 为空的cookie会被忽略
+func (j *Jar) Add(cookies ...*Cookie) {
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, c := range cookies {
+		if c == nil || c.Domain == "" || c.expired(now) {
+			continue
+		}
+
+		cookie := *c
+		cookie.Domain = strings.ToLower(strings.TrimPrefix(cookie.Domain, "."))
+		if cookie.Path == "" {
+			cookie.Path = "/"
+		}
+		if cookie.Creation.IsZero() {
+			cookie.Creation = now
+		}
+		j.entries[cookie.id()] = &cookie
+	}
+}
+
+// All 返回所有未过期的cookie 按 Domain、Path、Name 排序
+func (j *Jar) All() []*Cookie {
+	now := time.Now()
+
+	j.mu.Lock()
+	cookies := make([]*Cookie, 0, len(j.entries))
+	for id, c := range j.entries {
+		if c.expired(now) {
+			delete(j.entries, id)
+			continue
+		}
+		cookie := *c
+		cookies = append(cookies, &cookie)
+	}
+	j.mu.Unlock()
+
+	sort.Slice(cookies, func(i, k int) bool {
+		return cookies[i].id() < cookies[k].id()
+	})
+	return cookies
+}
+
+// Delete 删除cookie
+func (j *Jar) Delete(domain, path, name string) {
+	j.mu.Lock()
+	delete(j.entries, strings.ToLower(strings.TrimPrefix(domain, "."))+";"+path+";"+name)
+	j.mu.Unlock()
+}
+
+// Clear 清空所有cookie
+func (j *Jar) Clear() {
+	j.mu.Lock()
+	j.entries = make(map[string]*Cookie)
+	j.mu.Unlock()
+}
+
+// Cookies 实现 http.CookieJar
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil
+	}
+
+	host := canonicalHost(u.Host)
+
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	now := time.Now()
+	https := u.Scheme == "https"
+
+	j.mu.Lock()
+	var selected []*Cookie
+	for id, c := range j.entries {
+		if c.expired(now) {
+			delete(j.entries, id)
+			continue
+		}
+
+		if c.Secure && !https {
+			continue
+		}
+
+		if !domainMatch(c, host) || !pathMatch(c.Path, path) {
+			continue
+		}
+
+		selected = append(selected, c)
+	}
+	j.mu.Unlock()
+
+	// RFC 6265 5.4 路径长的在前 同样长度创建早的在前
+	sort.Slice(selected, func(i, k int) bool {
+		if len(selected[i].Path) != len(selected[k].Path) {
+			return len(selected[i].Path) > len(selected[k].Path)
+		}
+		if !selected[i].Creation.Equal(selected[k].Creation) {
+			return selected[i].Creation.Before(selected[k].Creation)
+		}
+		return selected[i].id() < selected[k].id()
+	})
+
+	cookies := make([]*http.Cookie, 0, len(selected))
+	for _, c := range selected {
+		cookies = append(cookies, &http.Cookie{Name: c.Name, Value: c.Value})
+	}
+
+	return cookies
+}
+
+// SetCookies 实现 http.CookieJar
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+
+	host := canonicalHost(u.Host)
+
+	now := time.Now()
+	defPath := defaultPath(u.Path)
+
+	j.mu.Lock()
+	for _, cookie := range cookies {
+		c, remove, ok := newEntry(cookie, host, defPath, now)
+		if !ok {
+			continue
+		}
+
+		id := c.id()
+		if remove {
+			delete(j.entries, id)
+			continue
+		}
+
+		if old, ok := j.entries[id]; ok {
+			c.Creation = old.Creation
+		}
+		j.entries[id] = c
+	}
+	j.mu.Unlock()
+
+	if j.autoSave {
+		_ = j.Save(context.Background())
+	}
+}
+
+// newEntry 按 RFC 6265 5.3 创建cookie remove 为 true 时删除已有的cookie
+func newEntry(cookie *http.Cookie, host, defPath string, now time.Time) (c *Cookie, remove, ok bool) {
+	c = &Cookie{
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+		Secure:   cookie.Secure,
+		HttpOnly: cookie.HttpOnly,
+		SameSite: cookie.SameSite,
+		Creation: now,
+	}
+
+	c.Path = cookie.Path
+	if c.Path == "" || c.Path[0] != '/' {
+		c.Path = defPath
+	}
+
+	domain, hostOnly, ok := domainAndType(host, cookie.Domain)
+	if !ok {
+		return nil, false, false
+	}
+	c.Domain, c.HostOnly = domain, hostOnly
+
+	switch {
+	case cookie.MaxAge < 0:
+		return c, true, true
+	case cookie.MaxAge > 0:
+		c.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+	case !cookie.Expires.IsZero():
+		if !cookie.Expires.After(now) {
+			return c, true, true
+		}
+		c.Expires = cookie.Expires
+	}
+
+	return c, false, true
+}
+
+// domainAndType 计算cookie的域名 host 为请求的域名
+func domainAndType(host, domain string) (string, bool, bool) {
+	if domain == "" {
+		return host, true, true
+	}
+
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	if net.ParseIP(host) != nil {
+		// IP 只能设置 host-only cookie
+		return host, true, host == domain
+	}
+
+	// 不允许设置在公共后缀上 除非就是请求的域名
+	if suffix, _ := publicsuffix.PublicSuffix(domain); suffix == domain {
+		return host, true, host == domain
+	}
+
+	if host != domain && !strings.HasSuffix(host, "."+domain) {
+		return "", false, false
+	}
+
+	return domain, false, true
+}
+
+func domainMatch(c *Cookie, host string) bool {
+	if c.Domain == host {
+		return true
+	}
+	return !c.HostOnly && strings.HasSuffix(host, "."+c.Domain)
+}
+
+// pathMatch RFC 6265 5.1.4
+func pathMatch(cookiePath, requestPath string) bool {
+	if requestPath == cookiePath {
+		return true
+	}
+
+	if strings.HasPrefix(requestPath, cookiePath) {
+		return cookiePath[len(cookiePath)-1] == '/' || requestPath[len(cookiePath)] == '/'
+	}
+
+	return false
+}
+
+// defaultPath RFC 6265 5.1.4
+func defaultPath(path string) string {
+	if path == "" || path[0] != '/' {
+		return "/"
+	}
+
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+
+	return path[:i]
+}
+
+func canonicalHost(host string) string {
+	if strings.Contains(host, ":") {
+		h, _, err := net.SplitHostPort(host)
+		if err != nil {
+			// 没有端口的 IPv6
+			h = strings.Trim(host, "[]")
+		}
+		host = h
+	}
+
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// ExportJSON 以JSON格式导出所有cookie
+func (j *Jar) ExportJSON(w io.Writer) error {
+	return WriteJSON(w, j.All())
+}
+
+// ImportJSON 导入JSON格式的cookie
+func (j *Jar) ImportJSON(r io.Reader) error {
+	cookies, err := ReadJSON(r)
+	if err != nil {
+		return err
+	}
+
+	j.Add(cookies...)
+	return nil
+}
+
+// ExportNetscape 以 Netscape cookies.txt 格式导出所有cookie
+func (j *Jar) ExportNetscape(w io.Writer) error {
+	return WriteNetscape(w, j.All())
+}
+
+// ImportNetscape 导入 Netscape cookies.txt 格式的cookie
+func (j *Jar) ImportNetscape(r io.Reader) error {
+	cookies, err := ReadNetscape(r)
+	if err != nil {
+		return err
+	}
+
+	j.Add(cookies...)
+	return nil
+}
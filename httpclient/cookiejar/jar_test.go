@@ -0,0 +1,182 @@
+package cookiejar
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func cookieNames(cookies []*http.Cookie) string {
+	names := make([]string, 0, len(cookies))
+	for _, c := range cookies {
+		names = append(names, c.Name+"="+c.Value)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestJar_SetCookies(t *testing.T) {
+	jar, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := mustParse(t, "https://www.example.com/account/login")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: ".example.com", Path: "/"},
+		{Name: "secure", Value: "3", Secure: true, Path: "/"},
+		{Name: "suffix", Value: "4", Domain: "com"},
+		{Name: "other", Value: "5", Domain: "other.com"},
+		{Name: "expired", Value: "6", MaxAge: -1},
+	})
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://www.example.com/account/info", "host=1 domain=2 secure=3"},
+		{"http://www.example.com/account", "host=1 domain=2"},
+		{"https://api.example.com/", "domain=2"},
+		{"https://www.example.com/", "domain=2 secure=3"},
+		{"https://other.com/", ""},
+	}
+	for _, tt := range tests {
+		if got := cookieNames(jar.Cookies(mustParse(t, tt.url))); got != tt.want {
+			t.Errorf("Cookies(%s) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	if got := len(jar.All()); got != 3 {
+		t.Fatalf("All() = %d cookies, want 3", got)
+	}
+
+	// 删除cookie
+	jar.SetCookies(u, []*http.Cookie{{Name: "domain", Domain: "example.com", Path: "/", MaxAge: -1}})
+	if got := cookieNames(jar.Cookies(mustParse(t, "https://api.example.com/"))); got != "" {
+		t.Fatalf("after delete got %q", got)
+	}
+}
+
+func TestJar_Concurrent(t *testing.T) {
+	jar, _ := New()
+	u := mustParse(t, "https://example.com/")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for k := 0; k < 100; k++ {
+				jar.SetCookies(u, []*http.Cookie{{Name: "n", Value: "v"}})
+				jar.Cookies(u)
+				jar.All()
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestJar_ExportImport(t *testing.T) {
+	jar, _ := New()
+	u := mustParse(t, "https://www.example.com/")
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "abc", HttpOnly: true},
+		{Name: "uid", Value: "1", Domain: "example.com", Path: "/", Expires: expires, Secure: true},
+	})
+
+	for _, format := range []string{"json", "netscape"} {
+		var buf bytes.Buffer
+		imported, _ := New()
+		var err error
+		if format == "json" {
+			if err = jar.ExportJSON(&buf); err == nil {
+				err = imported.ImportJSON(&buf)
+			}
+		} else {
+			if err = jar.ExportNetscape(&buf); err == nil {
+				err = imported.ImportNetscape(&buf)
+			}
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		for _, rawURL := range []string{"https://www.example.com/", "https://api.example.com/"} {
+			want := cookieNames(jar.Cookies(mustParse(t, rawURL)))
+			if got := cookieNames(imported.Cookies(mustParse(t, rawURL))); got != want {
+				t.Errorf("%s: Cookies(%s) = %q, want %q", format, rawURL, got, want)
+			}
+		}
+
+		all := imported.All()
+		if len(all) != 2 || !all[0].HttpOnly && !all[1].HttpOnly {
+			t.Errorf("%s: All() = %+v", format, all)
+		}
+	}
+}
+
+func TestReadNetscape(t *testing.T) {
+	data := "# Netscape HTTP Cookie File\n" +
+		"#HttpOnly_.example.com\tTRUE\t/\tTRUE\t0\tsid\tabc\n" +
+		"www.example.com\tFALSE\t/path\tFALSE\t2000000000\tempty\n"
+	cookies, err := ReadNetscape(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*Cookie{
+		{Domain: "example.com", Path: "/", Secure: true, HttpOnly: true, Name: "sid", Value: "abc"},
+		{Domain: "www.example.com", HostOnly: true, Path: "/path", Name: "empty", Expires: time.Unix(2000000000, 0)},
+	}
+	if !reflect.DeepEqual(cookies, want) {
+		for i := range cookies {
+			t.Errorf("ReadNetscape()[%d] = %+v", i, cookies[i])
+		}
+		t.FailNow()
+	}
+
+	if _, err = ReadNetscape(strings.NewReader("a\tb\n")); err == nil {
+		t.Fatal("want error for invalid line")
+	}
+}
+
+func TestFileStorage(t *testing.T) {
+	for _, format := range []Format{FormatJSON, FormatNetscape} {
+		storage := NewFileStorage(filepath.Join(t.TempDir(), "cookies"), format)
+		jar, err := New(WithStorage(storage), WithAutoSave(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		u := mustParse(t, "https://example.com/")
+		jar.SetCookies(u, []*http.Cookie{{Name: "sid", Value: "abc", MaxAge: 3600}})
+
+		restored, err := New(WithStorage(storage))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cookieNames(restored.Cookies(u)); got != "sid=abc" {
+			t.Fatalf("format %d: restored cookies = %q", format, got)
+		}
+
+		if err = restored.Save(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
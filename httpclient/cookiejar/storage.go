@@ -0,0 +1,131 @@
+package cookiejar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Storage cookie持久化存储
+type Storage interface {
+	Load(ctx context.Context) ([]*Cookie, error)
+	Save(ctx context.Context, cookies []*Cookie) error
+}
+
+// Format 文件存储格式
+type Format int
+
+const (
+	FormatJSON     Format = iota // JSON 数组
+	FormatNetscape               // Netscape cookies.txt curl 和浏览器插件通用
+)
+
+// FileStorage 保存到本地文件 写入时先写临时文件再重命名
+type FileStorage struct {
+	Path   string
+	Format Format
+}
+
+func NewFileStorage(path string, format Format) *FileStorage {
+	return &FileStorage{Path: path, Format: format}
+}
+
+// Load 文件不存在时返回空
+func (s *FileStorage) Load(ctx context.Context) ([]*Cookie, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Format == FormatNetscape {
+		return ReadNetscape(bytes.NewReader(data))
+	}
+
+	return unmarshalJSON(data)
+}
+
+func (s *FileStorage) Save(ctx context.Context, cookies []*Cookie) error {
+	var buf bytes.Buffer
+	if s.Format == FormatNetscape {
+		if err := WriteNetscape(&buf, cookies); err != nil {
+			return err
+		}
+	} else {
+		if err := WriteJSON(&buf, cookies); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+// RedisStorage 以JSON保存到redis的一个key 多个进程共享登录态
+type RedisStorage struct {
+	client     redis.UniversalClient
+	key        string
+	expiration time.Duration
+}
+
+// NewRedisStorage expiration 为0时不过期
+func NewRedisStorage(client redis.UniversalClient, key string, expiration time.Duration) *RedisStorage {
+	return &RedisStorage{client: client, key: key, expiration: expiration}
+}
+
+// Load key 不存在时返回空
+func (s *RedisStorage) Load(ctx context.Context) ([]*Cookie, error) {
+	data, err := s.client.Get(ctx, s.key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalJSON(data)
+}
+
+func (s *RedisStorage) Save(ctx context.Context, cookies []*Cookie) error {
+	data, err := json.Marshal(cookies)
+	if err != nil {
+		return err
+	}
+
+	return s.client.Set(ctx, s.key, data, s.expiration).Err()
+}
+
+func unmarshalJSON(data []byte) ([]*Cookie, error) {
+	var cookies []*Cookie
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		return nil, err
+	}
+
+	return cookies, nil
+}
@@ -0,0 +1,600 @@
+package httpclient
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	stdurl "net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/windzhu0514/go-utils/httpclient/metadata"
+)
+
+// ToCurl 把请求转换为 curl 命令 包含查询参数、请求头、cookie、body 和代理
+// io.Reader 类型的 body 会被读取到内存 上传文件使用文件路径或文件名
+func (r *Request) ToCurl() (string, error) {
+	reqURL, err := r.buildURL()
+	if err != nil {
+		return "", err
+	}
+
+	method := r.method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body []string
+	multipartForm := len(r.multipartFields) > 0
+	if multipartForm {
+		body = r.curlForm()
+	} else {
+		data, err := r.curlData()
+		if err != nil {
+			return "", err
+		}
+		if data != nil {
+			body = []string{"--data-raw", string(data)}
+		}
+	}
+
+	args := []string{"curl"}
+	switch {
+	case method == http.MethodHead:
+		args = append(args, "-I")
+	case method == http.MethodGet && body == nil, method == http.MethodPost && body != nil:
+	default:
+		args = append(args, "-X", method)
+	}
+	args = append(args, reqURL.String())
+
+	header := r.heads.Clone()
+	setMetadataHeaders(metadata.NewRequestContext(r.ctx, r.requestMetadata()), header, r.client.metadataHeaders)
+	if multipartForm {
+		// curl 生成 boundary
+		header.Del("Content-Type")
+	}
+	for _, key := range r.curlHeaderKeys(header) {
+		for _, value := range header[key] {
+			args = append(args, "-H", key+": "+value)
+		}
+	}
+
+	if cookies := r.curlCookies(reqURL); len(cookies) > 0 {
+		pairs := make([]string, len(cookies))
+		for i, cookie := range cookies {
+			pairs[i] = cookie.Name + "=" + cookie.Value
+		}
+		args = append(args, "-b", strings.Join(pairs, "; "))
+	}
+
+	if proxy := r.curlProxy(); proxy != "" {
+		args = append(args, "-x", proxy)
+	}
+	args = append(args, body...)
+
+	for i := 1; i < len(args); i++ {
+		args[i] = shellQuote(args[i])
+	}
+	return strings.Join(args, " "), nil
+}
+
+// curlData 编码后的 body io.Reader 类型的 body 读取后替换为 []byte 请求仍然可以发送
+func (r *Request) curlData() ([]byte, error) {
+	if reader, ok := r.body.(io.Reader); ok && len(r.formData) == 0 {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		if closer, ok := reader.(io.Closer); ok {
+			closer.Close()
+		}
+		r.body = data
+	}
+
+	getBody, err := r.bodyGetter(false)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := getBody()
+	if err != nil || reader == nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+func (r *Request) curlForm() []string {
+	var args []string
+	keys := make([]string, 0, len(r.formData))
+	for key := range r.formData {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range r.formData[key] {
+			args = append(args, "-F", key+"="+value)
+		}
+	}
+
+	for _, field := range r.multipartFields {
+		switch {
+		case field.path != "":
+			args = append(args, "-F", field.name+"=@"+field.path)
+		case field.reader != nil || field.filename != "":
+			args = append(args, "-F", field.name+"=@"+field.filename)
+		default:
+			args = append(args, "-F", field.name+"="+field.value)
+		}
+	}
+	return args
+}
+
+// curlHeaderKeys 先按设置的头部顺序 其余按字母顺序
+func (r *Request) curlHeaderKeys(header http.Header) []string {
+	keys := make([]string, 0, len(header))
+	seen := make(map[string]bool, len(header))
+	for _, key := range r.headerOrder {
+		key = http.CanonicalHeaderKey(key)
+		if _, ok := header[key]; ok && !seen[key] {
+			keys = append(keys, key)
+			seen[key] = true
+		}
+	}
+
+	rest := make([]string, 0, len(header))
+	for key := range header {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+// curlCookies 和 Do 一样 没有 cookie jar 时不发送 cookie
+func (r *Request) curlCookies(u *stdurl.URL) []*http.Cookie {
+	jar := r.client.client.Jar
+	if jar == nil {
+		return nil
+	}
+
+	var cookies []*http.Cookie
+	index := make(map[string]int)
+	add := func(list []*http.Cookie) {
+		for _, cookie := range list {
+			if i, ok := index[cookie.Name]; ok {
+				cookies[i] = cookie
+				continue
+			}
+			index[cookie.Name] = len(cookies)
+			cookies = append(cookies, cookie)
+		}
+	}
+	add(jar.Cookies(u))
+	add(r.client.clientCookies())
+	add(r.cookies)
+	return cookies
+}
+
+func (r *Request) curlProxy() string {
+	if r.proxy != "" {
+		return r.proxy
+	}
+	if r.ctx != nil {
+		if proxy, ok := r.ctx.Value(proxyContextKey{}).(*stdurl.URL); ok && proxy != nil {
+			return proxy.String()
+		}
+	}
+	return ""
+}
+
+// shellQuote 需要时用单引号包围
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_./:@%+=,", c)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// FromCurl 解析 curl 命令 如浏览器开发者工具中复制的 curl 命令 请求头保持命令中的顺序
+func FromCurl(command string) (*Request, error) {
+	return defaultClient.FromCurl(command)
+}
+
+// FromCurl 解析 curl 命令 返回这个client的请求
+func (c *Client) FromCurl(command string) (*Request, error) {
+	args, err := splitShellWords(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 || args[0] != "curl" {
+		return nil, fmt.Errorf("httpclient: not a curl command")
+	}
+
+	var (
+		method, rawURL, proxy string
+		heads                 [][2]string
+		data                  []string
+		forms                 [][2]string
+		get                   bool
+	)
+	addHead := func(key, value string) {
+		heads = append(heads, [2]string{key, value})
+	}
+	// noValueOption 不带值的选项 返回 false 表示需要值
+	noValueOption := func(name string) bool {
+		switch name {
+		case "-I", "--head":
+			method = http.MethodHead
+		case "-G", "--get":
+			get = true
+		}
+		return curlNoValueOptions[name]
+	}
+
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			rawURL = arg
+			continue
+		}
+		if arg == "--" {
+			if i+1 < len(args) {
+				rawURL = args[i+1]
+			}
+			break
+		}
+
+		// 短选项可以合并 如 -sSL 或者带值 如 -XPOST
+		var name, value string
+		var hasValue bool
+		if strings.HasPrefix(arg, "--") {
+			if noValueOption(arg) {
+				continue
+			}
+			name = arg
+		} else {
+			for j := 1; j < len(arg); j++ {
+				flag := "-" + arg[j:j+1]
+				if noValueOption(flag) {
+					continue
+				}
+				name = flag
+				if j+1 < len(arg) {
+					value, hasValue = arg[j+1:], true
+				}
+				break
+			}
+			if name == "" {
+				continue
+			}
+		}
+
+		if !curlValueOptions[name] {
+			return nil, fmt.Errorf("httpclient: unsupported curl option %s", name)
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("httpclient: curl option %s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		switch name {
+		case "-X", "--request":
+			method = value
+		case "--url":
+			rawURL = value
+		case "-H", "--header":
+			key, val, ok := strings.Cut(value, ":")
+			if !ok {
+				// "X-Empty;" 发送空的请求头
+				if key, ok = strings.CutSuffix(value, ";"); !ok {
+					return nil, fmt.Errorf("httpclient: invalid curl header %q", value)
+				}
+				addHead(strings.TrimSpace(key), "")
+				continue
+			}
+			// "X-Remove:" 表示不发送这个请求头
+			if val = strings.TrimSpace(val); val != "" {
+				addHead(strings.TrimSpace(key), val)
+			}
+		case "-A", "--user-agent":
+			addHead("User-Agent", value)
+		case "-e", "--referer":
+			addHead("Referer", value)
+		case "-b", "--cookie":
+			if !strings.Contains(value, "=") {
+				return nil, fmt.Errorf("httpclient: curl cookie file %q is not supported", value)
+			}
+			addHead("Cookie", value)
+		case "-u", "--user":
+			addHead("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(value)))
+		case "-x", "--proxy":
+			proxy = value
+		case "-d", "--data", "--data-ascii", "--data-binary", "--data-raw", "--data-urlencode":
+			d, err := curlDataValue(name, value)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, d)
+		case "-F", "--form":
+			key, val, ok := strings.Cut(value, "=")
+			if !ok {
+				return nil, fmt.Errorf("httpclient: invalid curl form %q", value)
+			}
+			forms = append(forms, [2]string{key, val})
+		}
+	}
+
+	if rawURL == "" {
+		return nil, fmt.Errorf("httpclient: curl command has no url")
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+
+	if get && len(data) > 0 {
+		sep := "?"
+		if strings.Contains(rawURL, "?") {
+			sep = "&"
+		}
+		rawURL += sep + strings.Join(data, "&")
+		data = nil
+	}
+	if method == "" {
+		method = http.MethodGet
+		if len(data) > 0 || len(forms) > 0 {
+			method = http.MethodPost
+		}
+	}
+
+	req := c.NewRequest(method, rawURL)
+	if len(heads) > 0 {
+		req.SetOrderedHeads(heads)
+	}
+	if proxy != "" {
+		req.SetProxy(proxy)
+	}
+	if len(data) > 0 {
+		if req.heads.Get("Content-Type") == "" {
+			req.SetHead("Content-Type", MIMEPOSTForm)
+		}
+		req.body = strings.Join(data, "&")
+	}
+	for _, form := range forms {
+		if err := curlFormField(req, form[0], form[1]); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// curlDataValue 处理 @file 和 --data-urlencode
+func curlDataValue(name, value string) (string, error) {
+	switch name {
+	case "--data-raw":
+		return value, nil
+	case "--data-urlencode":
+		// content =content name=content
+		if key, content, ok := strings.Cut(value, "="); ok {
+			if key == "" {
+				return stdurl.QueryEscape(content), nil
+			}
+			return key + "=" + stdurl.QueryEscape(content), nil
+		}
+		return stdurl.QueryEscape(value), nil
+	}
+
+	path, ok := strings.CutPrefix(value, "@")
+	if !ok {
+		return value, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if name == "--data-binary" {
+		return string(data), nil
+	}
+	// -d @file 去掉换行
+	return strings.NewReplacer("\r", "", "\n", "").Replace(string(data)), nil
+}
+
+// curlFormField name=value name=@file name=<file 忽略 ;type= 等属性
+func curlFormField(req *Request, name, value string) error {
+	switch {
+	case strings.HasPrefix(value, "@"):
+		path, _, _ := strings.Cut(value[1:], ";")
+		req.SetFileFromPath(name, path)
+	case strings.HasPrefix(value, "<"):
+		path, _, _ := strings.Cut(value[1:], ";")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		req.SetMultipartField(name, string(data))
+	default:
+		req.SetMultipartField(name, value)
+	}
+	return nil
+}
+
+// curlNoValueOptions 不带值的选项 大多数和请求内容无关 直接忽略
+var curlNoValueOptions = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true,
+	"-k": true, "--insecure": true, "-L": true, "--location": true,
+	"-v": true, "--verbose": true, "-i": true, "--include": true,
+	"-f": true, "--fail": true, "-g": true, "--globoff": true,
+	"-N": true, "--no-buffer": true, "--compressed": true,
+	"--http1.1": true, "--http2": true, "--http2-prior-knowledge": true,
+	"-I": true, "--head": true, "-G": true, "--get": true,
+}
+
+// curlValueOptions 带值的选项
+var curlValueOptions = map[string]bool{
+	"-X": true, "--request": true, "--url": true,
+	"-H": true, "--header": true, "-A": true, "--user-agent": true,
+	"-e": true, "--referer": true, "-b": true, "--cookie": true,
+	"-u": true, "--user": true, "-x": true, "--proxy": true,
+	"-d": true, "--data": true, "--data-ascii": true, "--data-binary": true,
+	"--data-raw": true, "--data-urlencode": true, "-F": true, "--form": true,
+	"-o": true, "--output": true, "-m": true, "--max-time": true, "--connect-timeout": true,
+}
+
+// splitShellWords 按 shell 规则拆分命令 支持单引号、双引号、$'...' 和续行
+func splitShellWords(s string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		runes   = []rune(s)
+		n       = len(runes)
+		errQuot = fmt.Errorf("httpclient: unterminated quote in curl command")
+	)
+
+	for i := 0; i < n; i++ {
+		c := runes[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			inWord = true
+			if i+1 < n {
+				i++
+				if runes[i] == '\n' {
+					// 续行
+					if word.Len() == 0 {
+						inWord = false
+					}
+					continue
+				}
+				if runes[i] == '\r' && i+1 < n && runes[i+1] == '\n' {
+					i++
+					if word.Len() == 0 {
+						inWord = false
+					}
+					continue
+				}
+				word.WriteRune(runes[i])
+			}
+		case c == '\'':
+			inWord = true
+			end := i + 1
+			for end < n && runes[end] != '\'' {
+				end++
+			}
+			if end >= n {
+				return nil, errQuot
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			i = end
+		case c == '"':
+			inWord = true
+			i++
+			for ; i < n && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < n && strings.ContainsRune("$`\"\\\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteRune(runes[i])
+			}
+			if i >= n {
+				return nil, errQuot
+			}
+		case c == '$' && i+1 < n && runes[i+1] == '\'':
+			inWord = true
+			end, err := readANSIQuoted(runes, i+2, &word)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		default:
+			inWord = true
+			word.WriteRune(c)
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// readANSIQuoted 解析 $'...' 中的转义 返回结束引号的位置
+func readANSIQuoted(runes []rune, i int, word *strings.Builder) (int, error) {
+	var buf []byte
+	flush := func() {
+		// \x 转义可能组成多字节的 utf8 字符
+		for len(buf) > 0 {
+			r, size := utf8.DecodeRune(buf)
+			word.WriteRune(r)
+			buf = buf[size:]
+		}
+	}
+
+	for ; i < len(runes); i++ {
+		c := runes[i]
+		if c == '\'' {
+			flush()
+			return i, nil
+		}
+		if c != '\\' || i+1 >= len(runes) {
+			flush()
+			word.WriteRune(c)
+			continue
+		}
+
+		i++
+		switch e := runes[i]; e {
+		case 'n':
+			buf = append(buf, '\n')
+		case 't':
+			buf = append(buf, '\t')
+		case 'r':
+			buf = append(buf, '\r')
+		case 'e', 'E':
+			buf = append(buf, 0x1b)
+		case 'x', 'u', 'U':
+			digits := map[rune]int{'x': 2, 'u': 4, 'U': 8}[e]
+			end := i + 1
+			for end < len(runes) && end-i-1 < digits && strings.ContainsRune("0123456789abcdefABCDEF", runes[end]) {
+				end++
+			}
+			v, err := strconv.ParseUint(string(runes[i+1:end]), 16, 32)
+			if err != nil {
+				return 0, fmt.Errorf("httpclient: invalid escape in curl command: %w", err)
+			}
+			if e == 'x' {
+				buf = append(buf, byte(v))
+			} else {
+				buf = utf8.AppendRune(buf, rune(v))
+			}
+			i = end - 1
+		default:
+			// \\ \' \" 等
+			buf = utf8.AppendRune(buf, e)
+		}
+	}
+	return 0, fmt.Errorf("httpclient: unterminated quote in curl command")
+}
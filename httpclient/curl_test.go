@@ -0,0 +1,142 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRequest_ToCurl(t *testing.T) {
+	jar, _ := cookiejar.New(nil)
+	client := NewClient(
+		WithJar(jar),
+		WithMetadata(map[string]string{"tenant": "t1"}),
+		WithMetadataHeaders(map[string]string{"tenant": "X-Tenant-Id"}),
+	)
+
+	req := client.NewRequest(http.MethodPost, "https://example.com/api?v=1").
+		KeepQueryParamOrder(true).
+		SetQueryParam("b", "2").
+		SetQueryParam("a", "x y").
+		SetOrderedHeads([][2]string{{"User-Agent", "test"}, {"Accept", "*/*"}}).
+		SetBody(MIMEJSON, map[string]string{"name": "it's"}).
+		AddCookie(&http.Cookie{Name: "sid", Value: "abc"}).
+		SetProxy("http://127.0.0.1:8888")
+
+	got, err := req.ToCurl()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `curl 'https://example.com/api?v=1&b=2&a=x+y' -H 'User-Agent: test' -H 'Accept: */*' ` +
+		`-H 'Content-Type: application/json' -H 'X-Tenant-Id: t1' -b sid=abc -x http://127.0.0.1:8888 ` +
+		`--data-raw '{"name":"it'\''s"}` + "\n'"
+	if got != want {
+		t.Fatalf("ToCurl() =\n%s\nwant\n%s", got, want)
+	}
+
+	got, _ = NewRequest(http.MethodDelete, "http://example.com/").ToCurl()
+	if got != "curl -X DELETE http://example.com/" {
+		t.Fatalf("ToCurl() = %s", got)
+	}
+
+	got, _ = NewRequest(http.MethodPost, "http://example.com/").SetFormData("k", "v").SetFileFromPath("file", "/tmp/a.txt").ToCurl()
+	if got != "curl http://example.com/ -F k=v -F file=@/tmp/a.txt" {
+		t.Fatalf("ToCurl() = %s", got)
+	}
+}
+
+func TestFromCurl(t *testing.T) {
+	type received struct {
+		method string
+		url    string
+		header http.Header
+		body   string
+	}
+	ch := make(chan received, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ch <- received{r.Method, r.URL.String(), r.Header, string(body)}
+	}))
+	defer s.Close()
+
+	// 浏览器开发者工具复制的格式
+	command := `curl '` + s.URL + `/api?q=1' \
+  -H 'accept: application/json' \
+  -H $'x-quote: it\'s \xe4\xbd\xa0好' \
+  -b 'a=1; b=2' \
+  -H "x-double: \"v\"" \
+  -H 'x-remove:' \
+  --data-raw '{"k":"v"}' \
+  --compressed -sS`
+	req, err := FromCurl(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = req.Do(); err != nil {
+		t.Fatal(err)
+	}
+	got := <-ch
+	if got.method != http.MethodPost || got.url != "/api?q=1" || got.body != `{"k":"v"}` {
+		t.Fatalf("got %+v", got)
+	}
+	for key, want := range map[string]string{
+		"Accept":       "application/json",
+		"X-Quote":      "it's 你好",
+		"Cookie":       "a=1; b=2",
+		"X-Double":     `"v"`,
+		"Content-Type": MIMEPOSTForm,
+	} {
+		if v := got.header.Get(key); v != want {
+			t.Errorf("%s = %q, want %q", key, v, want)
+		}
+	}
+	if _, ok := got.header["X-Remove"]; ok {
+		t.Error("X-Remove should not be sent")
+	}
+	if want := []string{"accept", "x-quote", "Cookie", "x-double"}; !reflect.DeepEqual(req.headerOrder, want) {
+		t.Errorf("header order = %v, want %v", req.headerOrder, want)
+	}
+
+	req, err = FromCurl(`curl -G -d a=1 --data-urlencode 'b=x y' -XPUT ` + s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = req.Do(); err != nil {
+		t.Fatal(err)
+	}
+	if got = <-ch; got.method != http.MethodPut || got.url != "/?a=1&b=x+y" || got.body != "" {
+		t.Fatalf("got %+v", got)
+	}
+
+	for _, command := range []string{"wget http://a", "curl -H", "curl --unknown http://a", "curl 'http://a", "curl -X GET"} {
+		if _, err := FromCurl(command); err == nil {
+			t.Errorf("FromCurl(%q) should fail", command)
+		}
+	}
+}
+
+func TestCurl_RoundTrip(t *testing.T) {
+	req := NewRequest(http.MethodPatch, "http://example.com/a b").
+		SetOrderedHeads([][2]string{{"X-B", "1"}, {"X-A", `'"$`}}).
+		SetBody(MIMEPlain, "line1\nline2 'q'").
+		SetProxy("socks5://127.0.0.1:1080")
+	command, err := req.ToCurl()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := FromCurl(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.method != req.method || parsed.url != "http://example.com/a%20b" || parsed.proxy != req.proxy ||
+		!reflect.DeepEqual(parsed.heads, req.heads) || !reflect.DeepEqual(parsed.headerOrder, []string{"X-B", "X-A", "Content-Type"}) {
+		t.Fatalf("parsed %+v from %s", parsed, command)
+	}
+	if body, _ := parsed.body.(string); body != "line1\nline2 'q'" {
+		t.Fatalf("body = %q", body)
+	}
+}
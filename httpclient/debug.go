@@ -2,6 +2,9 @@ package httpclient
 
 import "sync"
 
+// Debug 记录每个host的tls连接数和最近一次握手耗时
+//
+// Deprecated: 使用 MetricsCollector 通过 WithTlsConnOptEventHook 或 WithEventHook 统计
 type Debug struct {
 	mu               sync.Mutex
 	TlsConnCount     map[string]int64 // 此host建立过多少个tls链接
@@ -0,0 +1,516 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	defaultDNSTimeout     = 5 * time.Second
+	maxDNSCacheEntries    = 4096
+	dnsUDPMessageSize     = 1232
+	defaultDNSServerPort  = "53"
+	dnsNotFoundErrMessage = "no such host"
+)
+
+// 固定的 host 对应的地址 类似 curl --resolve
+// key 为 host 或 host:port host:port 优先 value 为逗号分隔的IP 也可以是另一个域名
+func WithHostOverrides(hosts map[string]string) ClientOption {
+	return func(client *Client) {
+		client.dnsResolver().addHosts(hosts)
+	}
+}
+
+// 缓存域名解析结果 使用 DNS 记录的 TTL 最长为 maxTTL
+// 使用系统解析时拿不到 TTL 缓存 maxTTL
+func WithDNSCache(maxTTL time.Duration) ClientOption {
+	return func(client *Client) {
+		client.dnsResolver().cacheTTL = maxTTL
+	}
+}
+
+// 使用指定的 DNS 服务器解析域名 addr 没有端口时使用53 不再读取 /etc/hosts
+func WithDNSServer(addr string) ClientOption {
+	return func(client *Client) {
+		client.dnsResolver().setServer(addr)
+	}
+}
+
+// 同 WithHostOverrides
+func WithTlsConnOptHostOverrides(hosts map[string]string) TlsConnOption {
+	return func(tr *Transport) {
+		tr.dnsResolver().addHosts(hosts)
+	}
+}
+
+// 同 WithDNSCache
+func WithTlsConnOptDNSCache(maxTTL time.Duration) TlsConnOption {
+	return func(tr *Transport) {
+		tr.dnsResolver().cacheTTL = maxTTL
+	}
+}
+
+// 同 WithDNSServer
+func WithTlsConnOptDNSServer(addr string) TlsConnOption {
+	return func(tr *Transport) {
+		tr.dnsResolver().setServer(addr)
+	}
+}
+
+// dnsResolver 第一次设置 DNS 选项时创建 替换 transport 的拨号
+// 只支持 *http.Transport 和 *Transport 其他 RoundTripper 不生效 之后替换 Transport 时继续使用
+func (c *Client) dnsResolver() *dnsResolver {
+	if c.resolver != nil {
+		return c.resolver
+	}
+
+	if c.client.Transport == nil {
+		// 不修改 http.DefaultTransport
+		c.client.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	switch tr := c.client.Transport.(type) {
+	case *Transport:
+		c.resolver = tr.dnsResolver()
+	case *http.Transport:
+		dial := tr.DialContext
+		if dial == nil {
+			dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+		}
+		c.resolver = newDNSResolver()
+		tr.DialContext = c.resolver.dialContext(dial)
+	default:
+		c.resolver = newDNSResolver()
+	}
+	return c.resolver
+}
+
+// withResolver 替换 Transport 时继续使用之前设置的 DNS 解析 Transport 自己设置的优先
+// 已经在使用的 Transport 不重复设置 *http.Transport 复制后再设置 不影响调用方共用的 Transport
+func (c *Client) withResolver(rt http.RoundTripper) http.RoundTripper {
+	if c.resolver == nil {
+		return rt
+	}
+
+	switch tr := rt.(type) {
+	case *Transport:
+		if tr.resolver == nil {
+			tr.resolver = c.resolver
+		} else {
+			c.resolver = tr.resolver
+		}
+	case *http.Transport:
+		if current, _ := c.client.Transport.(*http.Transport); current == tr {
+			return rt
+		}
+		tr = tr.Clone()
+		dial := tr.DialContext
+		if dial == nil {
+			dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+		}
+		tr.DialContext = c.resolver.dialContext(dial)
+		return tr
+	}
+	return rt
+}
+
+func (t *Transport) dnsResolver() *dnsResolver {
+	if t.resolver == nil {
+		t.resolver = newDNSResolver()
+	}
+	return t.resolver
+}
+
+// dnsResolver 拨号前解析域名 依次使用固定的 host、缓存和 DNS 服务器
+// 选项在创建 client 时设置 之后只读
+type dnsResolver struct {
+	hosts    map[string][]string // key 为小写的 host 或 host:port
+	server   string              // DNS 服务器地址 空时使用系统解析
+	cacheTTL time.Duration       // 缓存的最长时间 0 不缓存
+	now      func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]*dnsCacheEntry
+}
+
+// dnsCacheEntry 解析完成前 done 未关闭 并发解析同一个域名时等待第一次的结果
+type dnsCacheEntry struct {
+	done    chan struct{}
+	ips     []net.IP
+	err     error
+	expires time.Time
+}
+
+func newDNSResolver() *dnsResolver {
+	return &dnsResolver{
+		hosts: make(map[string][]string),
+		now:   time.Now,
+		cache: make(map[string]*dnsCacheEntry),
+	}
+}
+
+func (r *dnsResolver) addHosts(hosts map[string]string) {
+	for host, addrs := range hosts {
+		var values []string
+		for _, addr := range strings.Split(addrs, ",") {
+			if addr = strings.Trim(strings.TrimSpace(addr), "[]"); addr != "" {
+				values = append(values, addr)
+			}
+		}
+		r.hosts[strings.ToLower(host)] = values
+	}
+}
+
+func (r *dnsResolver) setServer(addr string) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), defaultDNSServerPort)
+	}
+	r.server = addr
+}
+
+// dialContext 解析域名后依次连接每个地址 r 为 nil 时直接返回 dial
+func (r *dnsResolver) dialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if r == nil {
+		return dial
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+
+		ips, err := r.resolve(ctx, host, port)
+		if err != nil {
+			return nil, err
+		}
+
+		var firstErr error
+		for _, ip := range ips {
+			if network == "tcp4" && ip.To4() == nil || network == "tcp6" && ip.To4() != nil {
+				continue
+			}
+			conn, err := dial(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		if firstErr == nil {
+			firstErr = &net.AddrError{Err: "no suitable address found", Addr: host}
+		}
+		return nil, firstErr
+	}
+}
+
+// lookupIP 给在本地解析域名的 socks 代理使用 r 为 nil 时使用系统解析
+func (r *dnsResolver) lookupIP(ctx context.Context, host, port string) ([]net.IP, error) {
+	if r == nil {
+		return systemLookupIP(ctx, host)
+	}
+	return r.resolve(ctx, host, port)
+}
+
+func systemLookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP)
+	}
+	return ips, nil
+}
+
+// resolve 触发 httptrace 的 DNSStart 和 DNSDone 事件和指标不受影响
+func (r *dnsResolver) resolve(ctx context.Context, host, port string) (ips []net.IP, err error) {
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+	if trace != nil && trace.DNSDone != nil {
+		defer func() {
+			addrs := make([]net.IPAddr, 0, len(ips))
+			for _, ip := range ips {
+				addrs = append(addrs, net.IPAddr{IP: ip})
+			}
+			trace.DNSDone(httptrace.DNSDoneInfo{Addrs: addrs, Err: err})
+		}()
+	}
+
+	host = strings.ToLower(host)
+	values, ok := r.hosts[net.JoinHostPort(host, port)]
+	if !ok {
+		values, ok = r.hosts[host]
+	}
+	if !ok {
+		return r.cachedLookup(ctx, host)
+	}
+
+	for _, value := range values {
+		if ip := net.ParseIP(value); ip != nil {
+			ips = append(ips, ip)
+			continue
+		}
+		// 指向另一个域名 不再使用固定的 host 避免循环
+		alias, err := r.cachedLookup(ctx, value)
+		if err != nil {
+			return nil, err
+		}
+		ips = append(ips, alias...)
+	}
+	return ips, nil
+}
+
+func (r *dnsResolver) cachedLookup(ctx context.Context, host string) ([]net.IP, error) {
+	if r.cacheTTL <= 0 {
+		ips, _, err := r.lookup(ctx, host)
+		return ips, err
+	}
+
+	r.mu.Lock()
+	entry, ok := r.cache[host]
+	if ok {
+		select {
+		case <-entry.done:
+			ok = entry.err == nil && r.now().Before(entry.expires)
+		default:
+		}
+	}
+	if !ok {
+		entry = &dnsCacheEntry{done: make(chan struct{})}
+		if len(r.cache) >= maxDNSCacheEntries {
+			r.removeExpired()
+		}
+		r.cache[host] = entry
+		// 其他调用方也在等待这次的结果 第一个调用方取消时不能中断解析
+		go r.fill(context.WithoutCancel(ctx), host, entry)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-entry.done:
+		return entry.ips, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fill 解析域名并保存到 entry 最长 defaultDNSTimeout
+func (r *dnsResolver) fill(ctx context.Context, host string, entry *dnsCacheEntry) {
+	ctx, cancel := context.WithTimeout(ctx, defaultDNSTimeout)
+	defer cancel()
+	ips, ttl, err := r.lookup(ctx, host)
+
+	r.mu.Lock()
+	entry.ips, entry.err = ips, err
+	entry.expires = r.now().Add(min(ttl, r.cacheTTL))
+	if err != nil && r.cache[host] == entry {
+		// 失败的结果不缓存
+		delete(r.cache, host)
+	}
+	close(entry.done)
+	r.mu.Unlock()
+}
+
+// removeExpired 调用方持有锁
+func (r *dnsResolver) removeExpired() {
+	now := r.now()
+	for host, entry := range r.cache {
+		select {
+		case <-entry.done:
+			if !now.Before(entry.expires) {
+				delete(r.cache, host)
+			}
+		default:
+		}
+	}
+}
+
+// lookup 返回的 ttl 为记录中最小的 TTL 使用系统解析时为 cacheTTL
+func (r *dnsResolver) lookup(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	if r.server == "" {
+		ips, err := systemLookupIP(ctx, host)
+		return ips, r.cacheTTL, err
+	}
+
+	type result struct {
+		ips []net.IP
+		ttl time.Duration
+		err error
+	}
+	types := []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA}
+	results := make([]result, len(types))
+	var wg sync.WaitGroup
+	for i, qtype := range types {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ips, ttl, err := r.exchange(ctx, host, qtype)
+			results[i] = result{ips, ttl, err}
+		}()
+	}
+	wg.Wait()
+
+	var (
+		ips      []net.IP
+		ttl      = time.Duration(-1)
+		firstErr error
+	)
+	for _, res := range results {
+		if res.err != nil {
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			continue
+		}
+		ips = append(ips, res.ips...)
+		if len(res.ips) > 0 && (ttl < 0 || res.ttl < ttl) {
+			ttl = res.ttl
+		}
+	}
+	if len(ips) == 0 {
+		if firstErr == nil {
+			firstErr = &net.DNSError{Err: dnsNotFoundErrMessage, Name: host, Server: r.server, IsNotFound: true}
+		}
+		return nil, 0, firstErr
+	}
+	return ips, ttl, nil
+}
+
+// exchange 通过 UDP 查询 响应被截断时改用 TCP
+func (r *dnsResolver) exchange(ctx context.Context, host string, qtype dnsmessage.Type) ([]net.IP, time.Duration, error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host, Server: r.server}
+	}
+	id := uint16(rand.Uint32())
+	query, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}).Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultDNSTimeout)
+		defer cancel()
+	}
+
+	msg, err := r.roundTrip(ctx, "udp", id, query)
+	if err == nil && msg.Truncated {
+		msg, err = r.roundTrip(ctx, "tcp", id, query)
+	}
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host, Server: r.server, IsTimeout: errors.Is(err, context.DeadlineExceeded)}
+	}
+
+	switch msg.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, &net.DNSError{Err: dnsNotFoundErrMessage, Name: host, Server: r.server, IsNotFound: true}
+	default:
+		return nil, 0, &net.DNSError{Err: "server misbehaving: " + msg.RCode.String(), Name: host, Server: r.server, IsTemporary: true}
+	}
+
+	var (
+		ips []net.IP
+		ttl = time.Duration(-1)
+	)
+	for _, answer := range msg.Answers {
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			ips = append(ips, net.IP(body.A[:]))
+		case *dnsmessage.AAAAResource:
+			ips = append(ips, net.IP(body.AAAA[:]))
+		case *dnsmessage.CNAMEResource:
+		default:
+			continue
+		}
+		// CNAME 链上的记录也要计算
+		if d := time.Duration(answer.Header.TTL) * time.Second; ttl < 0 || d < ttl {
+			ttl = d
+		}
+	}
+	return ips, max(ttl, 0), nil
+}
+
+func (r *dnsResolver) roundTrip(ctx context.Context, network string, id uint16, query []byte) (*dnsmessage.Message, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, r.server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	if network == "tcp" {
+		// TCP 的消息前面有两个字节的长度
+		framed := binary.BigEndian.AppendUint16(make([]byte, 0, 2+len(query)), uint16(len(query)))
+		query = append(framed, query...)
+	}
+	if _, err = conn.Write(query); err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+
+	for {
+		var buf []byte
+		if network == "tcp" {
+			var length [2]byte
+			if _, err = io.ReadFull(conn, length[:]); err != nil {
+				return nil, ctxErr(ctx, err)
+			}
+			buf = make([]byte, binary.BigEndian.Uint16(length[:]))
+			_, err = io.ReadFull(conn, buf)
+		} else {
+			buf = make([]byte, dnsUDPMessageSize)
+			var n int
+			n, err = conn.Read(buf)
+			buf = buf[:n]
+		}
+		if err != nil {
+			return nil, ctxErr(ctx, err)
+		}
+
+		var msg dnsmessage.Message
+		if err = msg.Unpack(buf); err != nil || msg.ID != id || !msg.Response {
+			if network == "tcp" {
+				return nil, errors.New("invalid DNS response")
+			}
+			// UDP 忽略不匹配的响应
+			continue
+		}
+		return &msg, nil
+	}
+}
+
+// ctxErr ctx 结束导致的读写错误返回 ctx 的错误
+func ctxErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,216 @@
+package httpclient
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// newFakeDNSServer 所有域名都解析为 127.0.0.1 TTL 为 ttl 秒 没有 AAAA 记录 delay 后响应
+func newFakeDNSServer(t *testing.T, ttl uint32, delay time.Duration) (string, *atomic.Int32) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	var queries atomic.Int32
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var msg dnsmessage.Message
+			if err = msg.Unpack(buf[:n]); err != nil || len(msg.Questions) != 1 {
+				continue
+			}
+			queries.Add(1)
+
+			q := msg.Questions[0]
+			msg.Header.Response = true
+			msg.Answers = nil
+			if q.Type == dnsmessage.TypeA {
+				msg.Answers = append(msg.Answers, dnsmessage.Resource{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: ttl},
+					Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+				})
+			}
+			resp, _ := msg.Pack()
+			time.AfterFunc(delay, func() {
+				_, _ = conn.WriteTo(resp, addr)
+			})
+		}
+	}()
+	return conn.LocalAddr().String(), &queries
+}
+
+func TestDNS_HostOverrides(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	}))
+	defer s.Close()
+	_, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+
+	client := NewClient(WithHostOverrides(map[string]string{
+		"example.test":             "127.0.0.1",
+		"other.test:" + port:       "[::1], 127.0.0.1", // 第一个地址连不上时使用下一个
+		"alias.test":               "localhost",
+		"unreachable.test:" + port: "::1",
+		"UPPER.TEST":               "127.0.0.1",
+	}), WithTimeout(3*time.Second))
+
+	for _, host := range []string{"example.test", "other.test", "alias.test", "upper.test"} {
+		statusCode, body, err := client.Get("http://" + net.JoinHostPort(host, port))
+		if err != nil || statusCode != http.StatusOK || string(body) != net.JoinHostPort(host, port) {
+			t.Fatalf("%s: status = %d, body = %q, err = %v", host, statusCode, body, err)
+		}
+	}
+
+	if _, err := client.NewRequest(http.MethodGet, "http://unreachable.test:"+port).Do(); err == nil {
+		t.Fatal("override to unreachable address should fail")
+	}
+
+	// 之后替换 Transport 继续使用
+	hosts := map[string]string{"example.test": "127.0.0.1"}
+	shared := &http.Transport{}
+	for _, c := range []*Client{
+		NewClient(WithHostOverrides(hosts), WithTransport(shared)), // 复制后设置 不修改调用方的 Transport
+		NewClient(WithHostOverrides(hosts)).SetTransport(NewTransport()),
+		NewClient(WithHostOverrides(hosts)).SetTransport(http.DefaultTransport), // 复制后设置 不修改 http.DefaultTransport
+	} {
+		if _, body, err := c.Get("http://example.test:" + port); err != nil || string(body) != "example.test:"+port {
+			t.Fatalf("%T: body = %q, err = %v", c.client.Transport, body, err)
+		}
+	}
+	if shared.DialContext != nil {
+		t.Fatal("caller's transport should not be modified")
+	}
+}
+
+func TestDNS_Cache(t *testing.T) {
+	server, queries := newFakeDNSServer(t, 30, 0)
+	r := newDNSResolver()
+	r.setServer(server)
+	r.cacheTTL = time.Minute
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	lookup := func() {
+		t.Helper()
+		ips, err := r.resolve(context.Background(), "svc.test", "80")
+		if err != nil || len(ips) != 1 || !ips[0].Equal(net.IPv4(127, 0, 0, 1)) {
+			t.Fatalf("ips = %v, err = %v", ips, err)
+		}
+	}
+
+	// 每次解析查询 A 和 AAAA
+	lookup()
+	lookup()
+	if queries.Load() != 2 {
+		t.Fatalf("queries = %d, want 2", queries.Load())
+	}
+	now = now.Add(31 * time.Second)
+	lookup()
+	if queries.Load() != 4 {
+		t.Fatalf("record TTL should expire the cache, queries = %d", queries.Load())
+	}
+
+	// TTL 超过 cacheTTL 时使用 cacheTTL
+	r.cacheTTL = 10 * time.Second
+	now = now.Add(time.Hour)
+	lookup()
+	now = now.Add(11 * time.Second)
+	lookup()
+	if queries.Load() != 8 {
+		t.Fatalf("cacheTTL should cap the record TTL, queries = %d", queries.Load())
+	}
+}
+
+func TestDNS_CacheCancel(t *testing.T) {
+	server, _ := newFakeDNSServer(t, 30, 200*time.Millisecond)
+	r := newDNSResolver()
+	r.setServer(server)
+	r.cacheTTL = time.Minute
+
+	// 第一个调用方取消后 等待同一次解析的调用方仍然拿到结果
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := r.resolve(ctx, "svc.test", "80")
+		first <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	second := make(chan error, 1)
+	go func() {
+		ips, err := r.resolve(context.Background(), "svc.test", "80")
+		if err == nil && (len(ips) != 1 || !ips[0].Equal(net.IPv4(127, 0, 0, 1))) {
+			t.Errorf("ips = %v", ips)
+		}
+		second <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if err := <-first; err != context.Canceled {
+		t.Fatalf("first err = %v, want %v", err, context.Canceled)
+	}
+	if err := <-second; err != nil {
+		t.Fatalf("second err = %v", err)
+	}
+}
+
+func TestDNS_Server(t *testing.T) {
+	server, queries := newFakeDNSServer(t, 300, 0)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer s.Close()
+	_, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	target := "http://svc.test:" + port
+
+	var dnsEvents atomic.Int32
+	hook := EventHookFunc(func(ctx context.Context, e Event) {
+		if e.Type == EventDNSDone && e.Err == nil {
+			dnsEvents.Add(1)
+		}
+	})
+	client := NewClient(WithDNSServer(server), WithDNSCache(time.Minute), WithEventHook(hook))
+	for i := 0; i < 2; i++ {
+		resp, err := client.NewRequest(http.MethodGet, target).SetHead("Connection", "close").Do()
+		if err != nil || resp.StatusCode() != http.StatusOK {
+			t.Fatal(err)
+		}
+	}
+	if queries.Load() != 2 || dnsEvents.Load() != 2 {
+		t.Fatalf("queries = %d, dns events = %d", queries.Load(), dnsEvents.Load())
+	}
+
+	// 自定义指纹的 Transport
+	tr := NewTransport(WithTlsConnOptDNSServer(server))
+	req, _ := http.NewRequest(http.MethodGet, target, nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+	tr = NewTransport(WithTlsConnOptHostOverrides(map[string]string{"secure.test": "127.0.0.1"}), WithTlsConnOptInsecureSkipVerify(true))
+	if body, err := tlsGet(t, tr, "https://secure.test:"+u.Port()); err != nil || body != "ok" {
+		t.Fatalf("body = %q, err = %v", body, err)
+	}
+}
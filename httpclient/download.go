@@ -0,0 +1,332 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrChecksumMismatch 下载文件的校验和不一致
+var ErrChecksumMismatch = errors.New("httpclient: checksum mismatch")
+
+// DownloadProgress 下载进度回调 total 未知时为 -1
+type DownloadProgress func(downloaded, total int64)
+
+type downloadOptions struct {
+	progress    DownloadProgress
+	resume      bool
+	hash        hash.Hash
+	checksum    string
+	concurrency int
+}
+
+type DownloadOption func(*downloadOptions)
+
+// WithDownloadProgress 设置下载进度回调 并发下载时回调会被串行调用
+func WithDownloadProgress(progress DownloadProgress) DownloadOption {
+	return func(o *downloadOptions) {
+		o.progress = progress
+	}
+}
+
+// WithDownloadResume 文件已存在时通过 Range 从文件末尾继续下载 服务端不支持 Range 时重新下载
+func WithDownloadResume() DownloadOption {
+	return func(o *downloadOptions) {
+		o.resume = true
+	}
+}
+
+// WithDownloadChecksum 下载完成后校验文件 checksum 为十六进制字符串
+// 例如 WithDownloadChecksum(sha256.New(), "e3b0c442...")
+func WithDownloadChecksum(h hash.Hash, checksum string) DownloadOption {
+	return func(o *downloadOptions) {
+		o.hash = h
+		o.checksum = checksum
+	}
+}
+
+// WithDownloadConcurrency 按 Range 分成 n 段并发下载 服务端不支持 Range 时退化为单连接下载
+// 并发下载总是重新下载整个文件
+func WithDownloadConcurrency(n int) DownloadOption {
+	return func(o *downloadOptions) {
+		o.concurrency = n
+	}
+}
+
+// Download 以流的方式下载到文件 不会把body读取到内存
+func (r *Request) Download(filename string, opts ...DownloadOption) error {
+	o := downloadOptions{concurrency: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var err error
+	if o.concurrency > 1 {
+		err = r.downloadParallel(filename, &o)
+	} else {
+		err = r.downloadSequential(filename, &o)
+	}
+	if err != nil {
+		return err
+	}
+
+	return o.verify(filename)
+}
+
+func (r *Request) downloadRequest() *Request {
+	req := r.clone()
+	if req.heads.Get("Accept-Encoding") == "" {
+		// 保证 Range 和 Content-Length 对应的是原始文件
+		req.heads.Set("Accept-Encoding", "identity")
+	}
+	return req
+}
+
+func (r *Request) downloadSequential(filename string, o *downloadOptions) error {
+	var offset int64
+	if o.resume {
+		if fi, err := os.Stat(filename); err == nil {
+			offset = fi.Size()
+		}
+	}
+
+	req := r.downloadRequest()
+	if offset > 0 {
+		req.SetHead("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := req.Do()
+	if err != nil {
+		return err
+	}
+	defer resp.resp.Body.Close()
+
+	return o.save(filename, resp, offset)
+}
+
+// save 保存单个连接的下载结果 offset 为请求的起始位置
+func (o *downloadOptions) save(filename string, resp *Response, offset int64) error {
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	total := resp.resp.ContentLength
+	switch resp.StatusCode() {
+	case http.StatusOK:
+		offset = 0
+	case http.StatusPartialContent:
+		start, _, size, ok := parseContentRange(resp.Headers().Get("Content-Range"))
+		if !ok || start != offset {
+			return fmt.Errorf("httpclient: download unexpected Content-Range %q", resp.Headers().Get("Content-Range"))
+		}
+		flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		total = size
+	case http.StatusRequestedRangeNotSatisfiable:
+		// 文件已经下载完成
+		_, _, size, ok := parseContentRange(resp.Headers().Get("Content-Range"))
+		if offset > 0 && ok && size == offset {
+			if o.progress != nil {
+				o.progress(offset, size)
+			}
+			return nil
+		}
+		return fmt.Errorf("httpclient: download unexpected status %s", resp.resp.Status)
+	default:
+		return fmt.Errorf("httpclient: download unexpected status %s", resp.resp.Status)
+	}
+
+	f, err := os.OpenFile(filename, flag, 0o644)
+	if err != nil {
+		return err
+	}
+
+	pw := &progressWriter{progress: o.progress, downloaded: offset, total: total}
+	_, err = io.Copy(f, io.TeeReader(resp.resp.Body, pw))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func (r *Request) downloadParallel(filename string, o *downloadOptions) (err error) {
+	// 先请求第一个字节判断服务端是否支持 Range
+	resp, err := r.downloadRequest().SetHead("Range", "bytes=0-0").Do()
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode() != http.StatusPartialContent {
+		// 不支持 Range 直接保存本次结果
+		defer resp.resp.Body.Close()
+		return o.save(filename, resp, 0)
+	}
+	resp.discard()
+
+	_, _, total, ok := parseContentRange(resp.Headers().Get("Content-Range"))
+	if !ok || total <= 0 {
+		// 文件大小未知 无法分段
+		return r.downloadSequential(filename, &downloadOptions{progress: o.progress})
+	}
+
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	if err = f.Truncate(total); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		pw       = &progressWriter{progress: o.progress, total: total}
+		chunk    = (total + int64(o.concurrency) - 1) / int64(o.concurrency)
+	)
+	for start := int64(0); start < total; start += chunk {
+		end := start + chunk - 1
+		if end >= total {
+			end = total - 1
+		}
+
+		wg.Add(1)
+		go func(start, end int64) {
+			defer wg.Done()
+			if err := r.downloadRange(ctx, f, start, end, pw); err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// downloadRange 下载 [start, end] 写入文件对应位置
+func (r *Request) downloadRange(ctx context.Context, f *os.File, start, end int64, pw *progressWriter) error {
+	req := r.downloadRequest().WithContext(ctx).SetHead("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	resp, err := req.Do()
+	if err != nil {
+		return err
+	}
+	defer resp.resp.Body.Close()
+
+	gotStart, gotEnd, _, ok := parseContentRange(resp.Headers().Get("Content-Range"))
+	if resp.StatusCode() != http.StatusPartialContent || !ok || gotStart != start || gotEnd != end {
+		return fmt.Errorf("httpclient: download range %d-%d unexpected response %s %q",
+			start, end, resp.resp.Status, resp.Headers().Get("Content-Range"))
+	}
+
+	n, err := io.Copy(io.NewOffsetWriter(f, start), io.TeeReader(resp.resp.Body, pw))
+	if err != nil {
+		return err
+	}
+
+	if n != end-start+1 {
+		return fmt.Errorf("httpclient: download range %d-%d got %d bytes: %w", start, end, n, io.ErrUnexpectedEOF)
+	}
+
+	return nil
+}
+
+func (o *downloadOptions) verify(filename string) error {
+	if o.hash == nil {
+		return nil
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	o.hash.Reset()
+	if _, err = io.Copy(o.hash, f); err != nil {
+		return err
+	}
+
+	if sum := hex.EncodeToString(o.hash.Sum(nil)); !strings.EqualFold(sum, o.checksum) {
+		return fmt.Errorf("%w: got %s, want %s", ErrChecksumMismatch, sum, o.checksum)
+	}
+
+	return nil
+}
+
+// progressWriter 统计下载进度
+type progressWriter struct {
+	mu         sync.Mutex
+	progress   DownloadProgress
+	downloaded int64
+	total      int64
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	pw.mu.Lock()
+	pw.downloaded += int64(len(p))
+	if pw.progress != nil {
+		pw.progress(pw.downloaded, pw.total)
+	}
+	pw.mu.Unlock()
+	return len(p), nil
+}
+
+// parseContentRange 解析 Content-Range: bytes 0-99/1000 或 bytes */1000
+// total 未知时为 -1
+func parseContentRange(value string) (start, end, total int64, ok bool) {
+	value, found := strings.CutPrefix(value, "bytes ")
+	if !found {
+		return 0, 0, 0, false
+	}
+
+	rangeValue, totalValue, found := strings.Cut(value, "/")
+	if !found {
+		return 0, 0, 0, false
+	}
+
+	total = -1
+	if totalValue != "*" {
+		var err error
+		if total, err = strconv.ParseInt(totalValue, 10, 64); err != nil {
+			return 0, 0, 0, false
+		}
+	}
+
+	if rangeValue == "*" {
+		return 0, 0, total, true
+	}
+
+	startValue, endValue, found := strings.Cut(rangeValue, "-")
+	if !found {
+		return 0, 0, 0, false
+	}
+
+	start, err := strconv.ParseInt(startValue, 10, 64)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+
+	end, err = strconv.ParseInt(endValue, 10, 64)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+
+	return start, end, total, true
+}
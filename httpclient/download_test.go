@@ -0,0 +1,160 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newDownloadServer(t *testing.T, content []byte) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func downloadContent() ([]byte, string) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	sum := sha256.Sum256(content)
+	return content, hex.EncodeToString(sum[:])
+}
+
+func TestRequest_Download(t *testing.T) {
+	content, checksum := downloadContent()
+	s := newDownloadServer(t, content)
+	filename := filepath.Join(t.TempDir(), "file.bin")
+
+	var downloaded, total int64
+	err := NewClient().NewRequest(http.MethodGet, s.URL).Download(filename,
+		WithDownloadProgress(func(d, t int64) { downloaded, total = d, t }),
+		WithDownloadChecksum(sha256.New(), checksum))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if downloaded != int64(len(content)) || total != int64(len(content)) {
+		t.Fatalf("progress = %d/%d, want %d", downloaded, total, len(content))
+	}
+}
+
+func TestRequest_DownloadResume(t *testing.T) {
+	content, checksum := downloadContent()
+	s := newDownloadServer(t, content)
+	filename := filepath.Join(t.TempDir(), "file.bin")
+	if err := os.WriteFile(filename, content[:1000], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var first int64 = -1
+	err := NewClient().NewRequest(http.MethodGet, s.URL).Download(filename,
+		WithDownloadResume(),
+		WithDownloadProgress(func(d, t int64) {
+			if first < 0 {
+				first = d
+			}
+		}),
+		WithDownloadChecksum(sha256.New(), checksum))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first <= 1000 {
+		t.Fatalf("first progress = %d, want resumed after 1000", first)
+	}
+
+	// 已经下载完成
+	err = NewClient().NewRequest(http.MethodGet, s.URL).Download(filename, WithDownloadResume(), WithDownloadChecksum(sha256.New(), checksum))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequest_DownloadParallel(t *testing.T) {
+	content, checksum := downloadContent()
+	s := newDownloadServer(t, content)
+	filename := filepath.Join(t.TempDir(), "file.bin")
+
+	var downloaded int64
+	err := NewClient().NewRequest(http.MethodGet, s.URL).Download(filename,
+		WithDownloadConcurrency(3),
+		WithDownloadProgress(func(d, t int64) { downloaded = d }),
+		WithDownloadChecksum(sha256.New(), checksum))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if downloaded != int64(len(content)) {
+		t.Fatalf("downloaded = %d, want %d", downloaded, len(content))
+	}
+}
+
+func TestRequest_DownloadChecksumMismatch(t *testing.T) {
+	content, _ := downloadContent()
+	s := newDownloadServer(t, content)
+	filename := filepath.Join(t.TempDir(), "file.bin")
+
+	err := NewClient().NewRequest(http.MethodGet, s.URL).Download(filename, WithDownloadChecksum(sha256.New(), "00"))
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("err = %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func TestResponse_ToFile(t *testing.T) {
+	content, _ := downloadContent()
+	s := newDownloadServer(t, content)
+	filename := filepath.Join(t.TempDir(), "file.bin")
+
+	resp, err := NewClient().NewRequest(http.MethodGet, s.URL).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = resp.ToFile(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Fatal("file content mismatch")
+	}
+
+	// 写入文件后 body 不能再读取
+	if _, err = resp.Body(); !errors.Is(err, ErrBodyConsumed) {
+		t.Fatalf("err = %v, want ErrBodyConsumed", err)
+	}
+	if _, err = resp.WriteTo(io.Discard); !errors.Is(err, ErrBodyConsumed) {
+		t.Fatalf("err = %v, want ErrBodyConsumed", err)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		value             string
+		start, end, total int64
+		ok                bool
+	}{
+		{"bytes 0-99/1000", 0, 99, 1000, true},
+		{"bytes 100-199/*", 100, 199, -1, true},
+		{"bytes */1000", 0, 0, 1000, true},
+		{"bytes 0-a/1000", 0, 0, 0, false},
+		{"0-99/1000", 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, end, total, ok := parseContentRange(tt.value)
+		if start != tt.start || end != tt.end || total != tt.total || ok != tt.ok {
+			t.Errorf("parseContentRange(%q) = %d, %d, %d, %v", tt.value, start, end, total, ok)
+		}
+	}
+}
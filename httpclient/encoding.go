@@ -0,0 +1,143 @@
+package httpclient
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+// decodeContent 按 Content-Encoding 解压 多个编码时按相反顺序解压
+// 返回的 io.ReadCloser 只释放解压器 不关闭 body
+func decodeContent(body io.Reader, contentEncoding string) (io.ReadCloser, error) {
+	var (
+		reader  = body
+		closers []io.Closer
+	)
+
+	encodings := strings.Split(contentEncoding, ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		switch strings.ToLower(strings.TrimSpace(encodings[i])) {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			var gr *gzip.Reader
+			gr, err = gzip.NewReader(reader)
+			if err == nil {
+				reader = gr
+				closers = append(closers, gr)
+			}
+		case "br":
+			reader = brotli.NewReader(reader)
+		case "deflate":
+			reader, err = newDeflateReader(reader)
+			if err == nil {
+				closers = append(closers, reader.(io.Closer))
+			}
+		case "zstd":
+			var zr *zstd.Decoder
+			zr, err = zstd.NewReader(reader, zstd.WithDecoderConcurrency(1))
+			if err == nil {
+				reader = zr
+				closers = append(closers, zr.IOReadCloser())
+			}
+		default:
+			err = fmt.Errorf("httpclient: unsupported Content-Encoding %q", encodings[i])
+		}
+
+		if err != nil {
+			closeAll(closers)
+			return nil, err
+		}
+	}
+
+	return &decodeReader{Reader: reader, closers: closers}, nil
+}
+
+// newDeflateReader HTTP 的 deflate 一般是 zlib 格式 也有服务端直接返回原始 deflate 数据
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+
+	return flate.NewReader(br), nil
+}
+
+type decodeReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d *decodeReader) Close() error {
+	return closeAll(d.closers)
+}
+
+func closeAll(closers []io.Closer) error {
+	var firstErr error
+	for _, closer := range closers {
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Text 返回转换为UTF-8的body
+// 依次按 BOM、Content-Type 的 charset 和 HTML meta 标签检测编码 例如 GBK、GB18030、Big5
+func (r *Response) Text() (string, error) {
+	body, err := r.Body()
+	if err != nil {
+		return "", err
+	}
+
+	preview := body
+	if len(preview) > 1024 {
+		preview = preview[:1024]
+	}
+
+	enc, _, _ := charset.DetermineEncoding(preview, r.Headers().Get("Content-Type"))
+	return decodeText(body, enc)
+}
+
+// TextWithCharset 按指定的编码转换body为UTF-8 label 例如 gbk、gb18030、big5
+func (r *Response) TextWithCharset(label string) (string, error) {
+	body, err := r.Body()
+	if err != nil {
+		return "", err
+	}
+
+	enc, _ := charset.Lookup(label)
+	if enc == nil {
+		return "", fmt.Errorf("httpclient: unsupported charset %q", label)
+	}
+
+	return decodeText(body, enc)
+}
+
+func decodeText(body []byte, enc encoding.Encoding) (string, error) {
+	if enc == encoding.Nop {
+		return string(body), nil
+	}
+
+	text, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(text), nil
+}
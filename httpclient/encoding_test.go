@@ -0,0 +1,146 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "zstd":
+		w, _ = zstd.NewWriter(&buf)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestResponse_BodyContentEncoding(t *testing.T) {
+	content := bytes.Repeat([]byte("hello world "), 100)
+	tests := []struct {
+		compress        []string
+		contentEncoding string
+	}{
+		{[]string{"gzip"}, "gzip"},
+		{[]string{"br"}, "br"},
+		{[]string{"deflate"}, "deflate"},
+		{[]string{"raw-deflate"}, "deflate"},
+		{[]string{"zstd"}, "zstd"},
+		{[]string{"gzip", "br"}, "gzip, br"},
+		{nil, "identity"},
+	}
+	for _, tt := range tests {
+		data := content
+		for _, encoding := range tt.compress {
+			data = compress(t, encoding, data)
+		}
+
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", tt.contentEncoding)
+			_, _ = w.Write(data)
+		}))
+
+		// 设置 Accept-Encoding 避免 Transport 自动解压
+		_, body, err := NewClient().NewRequest(http.MethodGet, s.URL).SetHead("Accept-Encoding", tt.contentEncoding).Byte()
+		s.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.contentEncoding, err)
+		}
+		if !bytes.Equal(body, content) {
+			t.Fatalf("%s: body mismatch", tt.contentEncoding)
+		}
+	}
+}
+
+func TestResponse_BodyUnsupportedEncoding(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "compress")
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer s.Close()
+
+	_, _, err := NewClient().NewRequest(http.MethodGet, s.URL).SetHead("Accept-Encoding", "compress").Byte()
+	if err == nil {
+		t.Fatal("want error for unsupported Content-Encoding")
+	}
+}
+
+func TestResponse_Text(t *testing.T) {
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().String("你好，世界")
+	big5, _ := traditionalchinese.Big5.NewEncoder().String("你好，世界")
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"content-type gbk", "text/html; charset=gbk", gbk},
+		{"content-type gb2312", "text/plain; charset=GB2312", gbk},
+		{"meta gb18030", "text/html", `<html><head><meta charset="gb18030"></head><body>` + gbk + `</body></html>`},
+		{"meta http-equiv big5", "text/html", `<meta http-equiv="Content-Type" content="text/html; charset=big5">` + big5},
+		{"utf-8", "application/json", `{"msg":"你好，世界"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer s.Close()
+
+			resp, err := NewClient().NewRequest(http.MethodGet, s.URL).Do()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			text, err := resp.Text()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains([]byte(text), []byte("你好，世界")) {
+				t.Fatalf("text = %q", text)
+			}
+		})
+	}
+}
+
+func TestResponse_TextWithCharset(t *testing.T) {
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().String("你好")
+	resp := &Response{resp: &http.Response{Header: http.Header{}}, body: []byte(gbk)}
+	text, err := resp.TextWithCharset("gbk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "你好" {
+		t.Fatalf("text = %q, want 你好", text)
+	}
+
+	if _, err = resp.TextWithCharset("unknown"); err == nil {
+		t.Fatal("want error for unknown charset")
+	}
+}
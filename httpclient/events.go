@@ -0,0 +1,237 @@
+package httpclient
+
+import (
+	"context"
+	golangTls "crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// EventType 请求过程中的事件类型
+type EventType int
+
+const (
+	EventDNSStart          EventType = iota // 开始解析域名
+	EventDNSDone                            // 域名解析完成 Duration 为解析耗时
+	EventDialStart                          // 开始建立tcp连接 Addr 为目标或代理地址
+	EventDialDone                           // tcp连接完成 Duration 为拨号耗时
+	EventTLSHandshakeStart                  // 开始tls握手
+	EventTLSHandshakeDone                   // tls握手完成 Protocol 为 ALPN 协商的协议
+	EventGotConn                            // 获得连接 Reused 表示是否复用连接池中的连接
+	EventFirstByte                          // 收到响应的第一个字节 Duration 从请求开始计算
+	EventDone                               // 请求完成 响应 body 读完或关闭时触发 Duration 从请求开始计算
+)
+
+var eventTypeNames = [...]string{
+	EventDNSStart:          "dns_start",
+	EventDNSDone:           "dns_done",
+	EventDialStart:         "dial_start",
+	EventDialDone:          "dial_done",
+	EventTLSHandshakeStart: "tls_handshake_start",
+	EventTLSHandshakeDone:  "tls_handshake_done",
+	EventGotConn:           "got_conn",
+	EventFirstByte:         "first_byte",
+	EventDone:              "done",
+}
+
+func (t EventType) String() string {
+	if t >= 0 && int(t) < len(eventTypeNames) {
+		return eventTypeNames[t]
+	}
+	return "unknown"
+}
+
+// Event 请求过程中的一个事件 不同类型的事件只填写相关的字段
+type Event struct {
+	Type       EventType
+	Host       string // 请求的 host
+	Addr       string // 拨号的地址
+	Protocol   string // ALPN 协商的协议
+	Reused     bool
+	Duration   time.Duration
+	StatusCode int
+	Err        error
+}
+
+// EventHook 接收请求过程中的事件 类似 httptrace.ClientTrace 同一请求的事件可能在不同的 goroutine 中触发
+type EventHook interface {
+	OnEvent(ctx context.Context, event Event)
+}
+
+// EventHookFunc 函数形式的 EventHook
+type EventHookFunc func(ctx context.Context, event Event)
+
+func (f EventHookFunc) OnEvent(ctx context.Context, event Event) {
+	f(ctx, event)
+}
+
+// 请求过程中的事件回调 可以设置多个 按顺序调用
+func WithTlsConnOptEventHook(hooks ...EventHook) TlsConnOption {
+	return func(tr *Transport) {
+		tr.hooks = append(tr.hooks, hooks...)
+	}
+}
+
+// eventTrace 把 httptrace 的回调转换为事件
+type eventTrace struct {
+	ctx   context.Context
+	hooks []EventHook
+	host  string
+	start time.Time
+
+	mu        sync.Mutex
+	dnsStart  time.Time
+	dialStart map[string]time.Time
+	tlsStart  time.Time
+	done      bool
+}
+
+// withEventTrace 在 context 中加入 httptrace 没有 hook 时返回 nil
+func withEventTrace(ctx context.Context, host string, hooks []EventHook) (context.Context, *eventTrace) {
+	if len(hooks) == 0 {
+		return ctx, nil
+	}
+
+	et := &eventTrace{
+		hooks:     hooks,
+		host:      host,
+		start:     time.Now(),
+		dialStart: make(map[string]time.Time),
+	}
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			et.mu.Lock()
+			et.dnsStart = time.Now()
+			et.mu.Unlock()
+			et.emit(Event{Type: EventDNSStart})
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			et.mu.Lock()
+			d := time.Since(et.dnsStart)
+			et.mu.Unlock()
+			et.emit(Event{Type: EventDNSDone, Duration: d, Err: info.Err})
+		},
+		ConnectStart: func(network, addr string) {
+			et.mu.Lock()
+			et.dialStart[addr] = time.Now()
+			et.mu.Unlock()
+			et.emit(Event{Type: EventDialStart, Addr: addr})
+		},
+		ConnectDone: func(network, addr string, err error) {
+			et.mu.Lock()
+			d := time.Since(et.dialStart[addr])
+			et.mu.Unlock()
+			et.emit(Event{Type: EventDialDone, Addr: addr, Duration: d, Err: err})
+		},
+		TLSHandshakeStart: func() {
+			et.mu.Lock()
+			et.tlsStart = time.Now()
+			et.mu.Unlock()
+			et.emit(Event{Type: EventTLSHandshakeStart})
+		},
+		TLSHandshakeDone: func(state golangTls.ConnectionState, err error) {
+			et.mu.Lock()
+			d := time.Since(et.tlsStart)
+			et.mu.Unlock()
+			et.emit(Event{Type: EventTLSHandshakeDone, Protocol: state.NegotiatedProtocol, Duration: d, Err: err})
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			et.emit(Event{Type: EventGotConn, Reused: info.Reused})
+		},
+		GotFirstResponseByte: func() {
+			et.emit(Event{Type: EventFirstByte, Duration: time.Since(et.start)})
+		},
+	})
+	et.ctx = ctx
+	return ctx, et
+}
+
+func (et *eventTrace) emit(event Event) {
+	event.Host = et.host
+	for _, hook := range et.hooks {
+		hook.OnEvent(et.ctx, event)
+	}
+}
+
+// finish 请求失败时立即触发 EventDone 否则在 body 读完或关闭时触发
+func (et *eventTrace) finish(resp *http.Response, err error) {
+	if et == nil {
+		return
+	}
+	if err != nil || resp == nil || resp.Body == nil {
+		et.doneOnce(resp, err)
+		return
+	}
+	resp.Body = &eventBody{ReadCloser: resp.Body, trace: et, resp: resp}
+}
+
+func (et *eventTrace) doneOnce(resp *http.Response, err error) {
+	et.mu.Lock()
+	if et.done {
+		et.mu.Unlock()
+		return
+	}
+	et.done = true
+	et.mu.Unlock()
+
+	event := Event{Type: EventDone, Duration: time.Since(et.start), Err: err}
+	if resp != nil {
+		event.StatusCode = resp.StatusCode
+	}
+	et.emit(event)
+}
+
+type eventBody struct {
+	io.ReadCloser
+	trace *eventTrace
+	resp  *http.Response
+}
+
+func (b *eventBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.trace.doneOnce(b.resp, nil)
+	} else if err != nil {
+		b.trace.doneOnce(b.resp, err)
+	}
+	return n, err
+}
+
+func (b *eventBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.trace.doneOnce(b.resp, nil)
+	return err
+}
+
+// traceTLSHandshakeStart 自定义的 Transport 使用 utls 握手 需要手动调用 httptrace 的回调
+func traceTLSHandshakeStart(ctx context.Context) {
+	if trace := httptrace.ContextClientTrace(ctx); trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+}
+
+func traceTLSHandshakeDone(ctx context.Context, state golangTls.ConnectionState, err error) {
+	if trace := httptrace.ContextClientTrace(ctx); trace != nil && trace.TLSHandshakeDone != nil {
+		trace.TLSHandshakeDone(state, err)
+	}
+}
+
+// connectionState httptrace 使用标准库的 ConnectionState
+func connectionState(conn *tls.UConn) golangTls.ConnectionState {
+	state := conn.ConnectionState()
+	return golangTls.ConnectionState{
+		Version:            state.Version,
+		HandshakeComplete:  state.HandshakeComplete,
+		DidResume:          state.DidResume,
+		CipherSuite:        state.CipherSuite,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+		ServerName:         state.ServerName,
+		PeerCertificates:   state.PeerCertificates,
+		VerifiedChains:     state.VerifiedChains,
+	}
+}
@@ -0,0 +1,159 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) OnEvent(ctx context.Context, event Event) {
+	r.mu.Lock()
+	r.events = append(r.events, event)
+	r.mu.Unlock()
+}
+
+func (r *eventRecorder) take() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := r.events
+	r.events = nil
+	return events
+}
+
+func eventTypes(events []Event) string {
+	names := make([]string, len(events))
+	for i, e := range events {
+		names[i] = e.Type.String()
+	}
+	return strings.Join(names, ",")
+}
+
+func TestEventHook_Transport(t *testing.T) {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	s.EnableHTTP2 = true
+	s.StartTLS()
+	defer s.Close()
+
+	recorder := &eventRecorder{}
+	tr := NewTransport(WithTlsConnOptInsecureSkipVerify(true), WithTlsConnOptEventHook(recorder))
+	if body, err := tlsGet(t, tr, s.URL); err != nil || body != "ok" {
+		t.Fatalf("body = %q, err = %v", body, err)
+	}
+	events := recorder.take()
+	if got, want := eventTypes(events), "dial_start,dial_done,tls_handshake_start,tls_handshake_done,got_conn,first_byte,done"; got != want {
+		t.Fatalf("events = %s, want %s", got, want)
+	}
+	if events[3].Protocol != "h2" || events[4].Reused {
+		t.Fatalf("handshake = %+v, got conn = %+v", events[3], events[4])
+	}
+	if done := events[len(events)-1]; done.StatusCode != http.StatusOK || done.Err != nil || done.Host != s.Listener.Addr().String() {
+		t.Fatalf("done = %+v", done)
+	}
+
+	if _, err := tlsGet(t, tr, s.URL); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := eventTypes(recorder.take()), "got_conn,first_byte,done"; got != want {
+		t.Fatalf("events = %s, want %s", got, want)
+	}
+}
+
+func TestEventHook_Client(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer s.Close()
+
+	recorder := &eventRecorder{}
+	metrics := NewMetricsCollector()
+	client := NewClient(WithEventHook(recorder, metrics))
+	for i := 0; i < 2; i++ {
+		if _, _, err := client.NewRequest(http.MethodGet, s.URL).String(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := eventTypes(recorder.take()), "dial_start,dial_done,got_conn,first_byte,done,got_conn,first_byte,done"; got != want {
+		t.Fatalf("events = %s, want %s", got, want)
+	}
+
+	if _, _, err := client.NewRequest(http.MethodGet, "http://127.0.0.1:1/").String(); err == nil {
+		t.Fatal("request should fail")
+	}
+	events := recorder.take()
+	if done := events[len(events)-1]; done.Type != EventDone || done.Err == nil {
+		t.Fatalf("done = %+v", done)
+	}
+
+	host := s.Listener.Addr().String()
+	stats := metrics.Stats(host)
+	if stats.Requests != 2 || stats.Errors != 0 || stats.NewConns != 1 || stats.ReusedConns != 1 || stats.Duration.Count != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if stats := metrics.Stats("127.0.0.1:1"); stats.Requests != 1 || stats.Errors != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	var buf bytes.Buffer
+	if err := metrics.WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"# TYPE httpclient_requests_total counter",
+		`httpclient_requests_total{host="` + host + `"} 2`,
+		`httpclient_request_errors_total{host="127.0.0.1:1"} 1`,
+		`httpclient_connections_total{host="` + host + `",reused="true"} 1`,
+		"# TYPE httpclient_request_duration_seconds histogram",
+		`httpclient_request_duration_seconds_bucket{host="` + host + `",le="+Inf"} 2`,
+		`httpclient_request_duration_seconds_count{host="` + host + `"} 2`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("missing %q in\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestMetricsCollector_Histogram(t *testing.T) {
+	m := NewMetricsCollector(1, 0.1)
+	for _, d := range []float64{0.05, 0.1, 0.5, 2} {
+		m.OnEvent(context.Background(), Event{Type: EventDone, Host: `a"b`, Duration: secondsToDuration(d)})
+	}
+	m.OnEvent(context.Background(), Event{Type: EventDone, Host: `a"b`, Err: errors.New("fail")})
+
+	stats := m.Stats(`a"b`)
+	if stats.Requests != 5 || stats.Errors != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if got := stats.Duration.Counts; len(got) != 3 || got[0] != 3 || got[1] != 1 || got[2] != 1 {
+		t.Fatalf("counts = %v", got)
+	}
+
+	var buf bytes.Buffer
+	_ = m.WritePrometheus(&buf)
+	for _, line := range []string{
+		`httpclient_request_duration_seconds_bucket{host="a\"b",le="0.1"} 3`,
+		`httpclient_request_duration_seconds_bucket{host="a\"b",le="1"} 4`,
+		`httpclient_request_duration_seconds_bucket{host="a\"b",le="+Inf"} 5`,
+		`httpclient_request_duration_seconds_sum{host="a\"b"} 2.65`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("missing %q in\n%s", line, buf.String())
+		}
+	}
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
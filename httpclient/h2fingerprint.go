@@ -0,0 +1,650 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+const (
+	// x/net/http2 Transport 内部使用的接收窗口
+	h2TransportStreamFlow = 4 << 20
+	h2TransportConnFlow   = 1<<30 + 65535
+
+	h2FrameHeaderLen = 9
+	h2MaxFrameSize   = 16384 // 对端允许的最小 SETTINGS_MAX_FRAME_SIZE
+)
+
+// 不设置 HTTP2Fingerprint 时 x/net/http2 的指纹
+const defaultAkamaiFingerprint = "2:0;4:4194304;6:10485760|1073741824|0|a,m,p,s"
+
+var akamaiPseudoHeaders = map[string]string{
+	"m": ":method",
+	"a": ":authority",
+	"s": ":scheme",
+	"p": ":path",
+}
+
+// HTTP2Priority 连接建立后发送的 PRIORITY 帧
+type HTTP2Priority struct {
+	StreamID uint32
+	http2.PriorityParam
+}
+
+// HTTP2Fingerprint 自定义http2指纹 对应 Akamai 指纹的四个部分
+type HTTP2Fingerprint struct {
+	Settings          []http2.Setting      // 按顺序发送的 SETTINGS ENABLE_PUSH 只能为0
+	WindowUpdate      uint32               // 连接级 WINDOW_UPDATE 的增量 0 不发送
+	Priorities        []HTTP2Priority      // SETTINGS 后发送的 PRIORITY 帧
+	PseudoHeaderOrder []string             // 例如 :method :authority :scheme :path 为空时使用默认顺序
+	HeaderPriority    *http2.PriorityParam // HEADERS 帧携带的优先级 nil 不携带
+	HeaderOrder       []string             // 普通头部的顺序 小写 不在列表中的按原顺序放在后面 不属于 Akamai 指纹
+}
+
+// ParseAkamaiFingerprint 解析 Akamai http2 指纹
+// 例如 1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p
+func ParseAkamaiFingerprint(fingerprint string) (*HTTP2Fingerprint, error) {
+	parts := strings.Split(strings.TrimSpace(fingerprint), "|")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("httpclient: invalid akamai fingerprint %q: want 4 parts, got %d", fingerprint, len(parts))
+	}
+
+	fp := &HTTP2Fingerprint{}
+	for _, setting := range strings.Split(parts[0], ";") {
+		if setting == "" {
+			continue
+		}
+		kv := strings.Split(setting, ":")
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("httpclient: invalid akamai setting %q", setting)
+		}
+		id, err1 := strconv.ParseUint(kv[0], 10, 16)
+		val, err2 := strconv.ParseUint(kv[1], 10, 32)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("httpclient: invalid akamai setting %q", setting)
+		}
+		fp.Settings = append(fp.Settings, http2.Setting{ID: http2.SettingID(id), Val: uint32(val)})
+	}
+
+	if parts[1] != "00" {
+		windowUpdate, err := strconv.ParseUint(parts[1], 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("httpclient: invalid akamai window update %q", parts[1])
+		}
+		fp.WindowUpdate = uint32(windowUpdate)
+	}
+
+	if parts[2] != "0" {
+		for _, priority := range strings.Split(parts[2], ",") {
+			fields := strings.Split(priority, ":")
+			if len(fields) != 4 {
+				return nil, fmt.Errorf("httpclient: invalid akamai priority %q", priority)
+			}
+			var values [4]uint64
+			for i, field := range fields {
+				v, err := strconv.ParseUint(field, 10, 32)
+				if err != nil {
+					return nil, fmt.Errorf("httpclient: invalid akamai priority %q", priority)
+				}
+				values[i] = v
+			}
+			if values[3] < 1 || values[3] > 256 {
+				return nil, fmt.Errorf("httpclient: invalid akamai priority weight %q", priority)
+			}
+			fp.Priorities = append(fp.Priorities, HTTP2Priority{
+				StreamID: uint32(values[0]),
+				PriorityParam: http2.PriorityParam{
+					Exclusive: values[1] == 1,
+					StreamDep: uint32(values[2]),
+					Weight:    uint8(values[3] - 1),
+				},
+			})
+		}
+	}
+
+	for _, name := range strings.Split(parts[3], ",") {
+		header, ok := akamaiPseudoHeaders[name]
+		if !ok {
+			return nil, fmt.Errorf("httpclient: invalid akamai pseudo header %q", name)
+		}
+		fp.PseudoHeaderOrder = append(fp.PseudoHeaderOrder, header)
+	}
+
+	return fp, fp.validate()
+}
+
+// String 返回 Akamai 指纹
+func (fp *HTTP2Fingerprint) String() string {
+	if fp == nil {
+		return defaultAkamaiFingerprint
+	}
+
+	settings := make([]string, 0, len(fp.Settings))
+	for _, s := range fp.Settings {
+		settings = append(settings, fmt.Sprintf("%d:%d", s.ID, s.Val))
+	}
+
+	windowUpdate := "00"
+	if fp.WindowUpdate > 0 {
+		windowUpdate = strconv.FormatUint(uint64(fp.WindowUpdate), 10)
+	}
+
+	priorities := "0"
+	if len(fp.Priorities) > 0 {
+		ps := make([]string, 0, len(fp.Priorities))
+		for _, p := range fp.Priorities {
+			exclusive := 0
+			if p.Exclusive {
+				exclusive = 1
+			}
+			ps = append(ps, fmt.Sprintf("%d:%d:%d:%d", p.StreamID, exclusive, p.StreamDep, int(p.Weight)+1))
+		}
+		priorities = strings.Join(ps, ",")
+	}
+
+	pseudo := "a,m,p,s"
+	if len(fp.PseudoHeaderOrder) > 0 {
+		names := make([]string, 0, len(fp.PseudoHeaderOrder))
+		for _, header := range fp.PseudoHeaderOrder {
+			names = append(names, strings.TrimPrefix(header, ":")[:1])
+		}
+		pseudo = strings.Join(names, ",")
+	}
+
+	return strings.Join([]string{strings.Join(settings, ";"), windowUpdate, priorities, pseudo}, "|")
+}
+
+func (fp *HTTP2Fingerprint) validate() error {
+	for _, s := range fp.Settings {
+		if s.ID == http2.SettingEnablePush && s.Val != 0 {
+			return fmt.Errorf("httpclient: http2 fingerprint: server push is not supported")
+		}
+		if err := s.Valid(); err != nil {
+			return fmt.Errorf("httpclient: http2 fingerprint: %w", err)
+		}
+	}
+
+	if fp.WindowUpdate > 1<<31-1-65535 {
+		return fmt.Errorf("httpclient: http2 fingerprint: window update %d too large", fp.WindowUpdate)
+	}
+
+	for _, header := range fp.PseudoHeaderOrder {
+		switch header {
+		case ":method", ":authority", ":scheme", ":path", ":protocol":
+		default:
+			return fmt.Errorf("httpclient: http2 fingerprint: invalid pseudo header %q", header)
+		}
+	}
+
+	return nil
+}
+
+// configure 让 http2.Transport 的限制和发送的 SETTINGS 一致
+func (fp *HTTP2Fingerprint) configure(tr *http2.Transport) {
+	for _, s := range fp.Settings {
+		switch s.ID {
+		case http2.SettingHeaderTableSize:
+			tr.MaxDecoderHeaderTableSize = s.Val
+		case http2.SettingMaxHeaderListSize:
+			tr.MaxHeaderListSize = s.Val
+		case http2.SettingMaxFrameSize:
+			tr.MaxReadFrameSize = s.Val
+		}
+	}
+}
+
+// orderHeaderFields 伪头部在前 按 pseudoOrder 排序 普通头部按 headerOrder 排序
+func orderHeaderFields(fields []hpack.HeaderField, pseudoOrder, headerOrder []string) []hpack.HeaderField {
+	var pseudo, regular []hpack.HeaderField
+	for _, f := range fields {
+		if f.IsPseudo() {
+			pseudo = append(pseudo, f)
+		} else {
+			regular = append(regular, f)
+		}
+	}
+
+	sortByOrder(pseudo, pseudoOrder, func(f hpack.HeaderField) string { return f.Name })
+	sortByOrder(regular, headerOrder, func(f hpack.HeaderField) string { return f.Name })
+	return append(pseudo, regular...)
+}
+
+// sortByOrder 按名字在 order 中的位置稳定排序 不在 order 中的保持原顺序放在后面
+func sortByOrder[T any](values []T, order []string, name func(T) string) {
+	if len(order) == 0 {
+		return
+	}
+
+	index := make(map[string]int, len(order))
+	for i, n := range order {
+		index[strings.ToLower(n)] = i
+	}
+	rank := func(v T) int {
+		if i, ok := index[strings.ToLower(name(v))]; ok {
+			return i
+		}
+		return len(order)
+	}
+	sort.SliceStable(values, func(i, k int) bool {
+		return rank(values[i]) < rank(values[k])
+	})
+}
+
+// AkamaiFingerprint 获取http2的 Akamai 指纹 和 JA3() 一样返回指纹和md5
+func (transpoort *Transport) AkamaiFingerprint() (string, string) {
+	fingerprint := transpoort.HTTP2Fingerprint.String()
+	sum := md5.Sum([]byte(fingerprint))
+	return fingerprint, hex.EncodeToString(sum[:])
+}
+
+// h2FingerprintConn 改写 http2.Transport 写出的帧实现自定义指纹
+// 1. 设置了指纹时替换连接建立时的 SETTINGS 和 WINDOW_UPDATE 并追加 PRIORITY 帧
+// 2. 重新编码 HEADERS 按请求或指纹调整头部顺序和优先级
+// 3. 发送的窗口比 http2.Transport 内部的大时 暂存超出 http2.Transport 窗口的 DATA 帧 避免流控错误
+type h2FingerprintConn struct {
+	net.Conn
+	fp     *HTTP2Fingerprint
+	custom bool // 是否设置了指纹 没有设置时只调整头部顺序
+
+	// 写 由 http2.Transport 串行调用
+	wmu          sync.Mutex
+	wbuf         []byte
+	prefaceDone  bool
+	settingsDone bool
+	windowDone   bool
+	hdec         *hpack.Decoder
+	henc         *hpack.Encoder
+	hbuf         bytes.Buffer
+	hblock       []byte
+	hstream      uint32
+	hendStream   bool
+	werr         error
+
+	// 读
+	readOnce       sync.Once
+	mu             sync.Mutex
+	cond           *sync.Cond
+	ready          bytes.Buffer
+	rerr           error
+	connAvail      int64
+	streamAvail    map[uint32]int64
+	held           map[uint32][][]byte
+	resetStreams   map[uint32]bool
+	peerTableSize  uint32
+	peerTableDirty bool
+}
+
+// newH2FingerprintConn fp 为 nil 时不修改 SETTINGS 等帧
+func newH2FingerprintConn(conn net.Conn, fp *HTTP2Fingerprint) *h2FingerprintConn {
+	custom := fp != nil
+	if fp == nil {
+		fp = &HTTP2Fingerprint{}
+	}
+
+	c := &h2FingerprintConn{
+		Conn:         conn,
+		fp:           fp,
+		custom:       custom,
+		hdec:         hpack.NewDecoder(4096, nil),
+		connAvail:    h2TransportConnFlow,
+		streamAvail:  make(map[uint32]int64),
+		held:         make(map[uint32][][]byte),
+		resetStreams: make(map[uint32]bool),
+	}
+	c.henc = hpack.NewEncoder(&c.hbuf)
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *h2FingerprintConn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.werr != nil {
+		return 0, c.werr
+	}
+
+	c.wbuf = append(c.wbuf, p...)
+	var out []byte
+	if !c.prefaceDone {
+		if len(c.wbuf) < len(http2.ClientPreface) {
+			return len(p), nil
+		}
+		out = append(out, c.wbuf[:len(http2.ClientPreface)]...)
+		c.wbuf = c.wbuf[len(http2.ClientPreface):]
+		c.prefaceDone = true
+	}
+
+	for len(c.wbuf) >= h2FrameHeaderLen {
+		n := h2FrameHeaderLen + (int(c.wbuf[0])<<16 | int(c.wbuf[1])<<8 | int(c.wbuf[2]))
+		if len(c.wbuf) < n {
+			break
+		}
+
+		var err error
+		out, err = c.writeFrame(out, c.wbuf[:n])
+		if err != nil {
+			c.werr = err
+			return 0, err
+		}
+		c.wbuf = c.wbuf[n:]
+	}
+	c.wbuf = append([]byte(nil), c.wbuf...)
+
+	if len(out) > 0 {
+		if _, err := c.Conn.Write(out); err != nil {
+			c.werr = err
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// writeFrame 改写一个 http2.Transport 写出的帧 追加到 out
+func (c *h2FingerprintConn) writeFrame(out, frame []byte) ([]byte, error) {
+	typ := http2.FrameType(frame[3])
+	flags := http2.Flags(frame[4])
+	streamID := binary.BigEndian.Uint32(frame[5:9]) & (1<<31 - 1)
+	payload := frame[h2FrameHeaderLen:]
+
+	switch typ {
+	case http2.FrameSettings:
+		if c.custom && !c.settingsDone && !flags.Has(http2.FlagSettingsAck) {
+			c.settingsDone = true
+			payload := make([]byte, 0, 6*len(c.fp.Settings))
+			for _, s := range c.fp.Settings {
+				payload = binary.BigEndian.AppendUint16(payload, uint16(s.ID))
+				payload = binary.BigEndian.AppendUint32(payload, s.Val)
+			}
+			return appendH2Frame(out, http2.FrameSettings, 0, 0, payload), nil
+		}
+	case http2.FrameWindowUpdate:
+		increment := binary.BigEndian.Uint32(payload) & (1<<31 - 1)
+		if streamID == 0 && !c.windowDone {
+			// 连接建立时的 WINDOW_UPDATE 已经计入 h2TransportConnFlow
+			c.windowDone = true
+			if !c.custom {
+				return append(out, frame...), nil
+			}
+			if c.fp.WindowUpdate > 0 {
+				out = appendH2Frame(out, http2.FrameWindowUpdate, 0, 0, binary.BigEndian.AppendUint32(nil, c.fp.WindowUpdate))
+			}
+			for _, p := range c.fp.Priorities {
+				out = appendH2Frame(out, http2.FramePriority, 0, p.StreamID, appendH2Priority(nil, p.PriorityParam))
+			}
+			return out, nil
+		}
+		c.addWindow(streamID, increment)
+	case http2.FrameRSTStream:
+		c.resetStream(streamID)
+	case http2.FrameHeaders:
+		if flags.Has(http2.FlagHeadersPadded) {
+			if len(payload) == 0 || int(payload[0]) >= len(payload) {
+				return out, http2.ConnectionError(http2.ErrCodeProtocol)
+			}
+			payload = payload[1 : len(payload)-int(payload[0])]
+		}
+		if flags.Has(http2.FlagHeadersPriority) {
+			payload = payload[5:]
+		}
+		c.hstream = streamID
+		c.hendStream = flags.Has(http2.FlagHeadersEndStream)
+		c.hblock = append(c.hblock[:0], payload...)
+		if flags.Has(http2.FlagHeadersEndHeaders) {
+			return c.appendHeaders(out)
+		}
+		return out, nil
+	case http2.FrameContinuation:
+		c.hblock = append(c.hblock, payload...)
+		if flags.Has(http2.FlagContinuationEndHeaders) {
+			return c.appendHeaders(out)
+		}
+		return out, nil
+	}
+
+	return append(out, frame...), nil
+}
+
+// appendHeaders 按指纹重新编码头部
+func (c *h2FingerprintConn) appendHeaders(out []byte) ([]byte, error) {
+	fields, err := c.hdec.DecodeFull(c.hblock)
+	if err != nil {
+		return out, err
+	}
+
+	c.mu.Lock()
+	if c.peerTableDirty {
+		c.peerTableDirty = false
+		c.henc.SetMaxDynamicTableSize(c.peerTableSize)
+	}
+	c.mu.Unlock()
+
+	c.hbuf.Reset()
+	pseudoOrder, headerOrder := c.fp.PseudoHeaderOrder, c.fp.HeaderOrder
+	fields, order, pseudo := extractHeaderOrder(fields)
+	if len(order) > 0 {
+		headerOrder = order
+	}
+	if len(pseudo) > 0 {
+		pseudoOrder = pseudo
+	}
+	for _, f := range orderHeaderFields(fields, pseudoOrder, headerOrder) {
+		_ = c.henc.WriteField(f)
+	}
+
+	block := c.hbuf.Bytes()
+	var flags http2.Flags
+	var payload []byte
+	if c.hendStream {
+		flags |= http2.FlagHeadersEndStream
+	}
+	if c.fp.HeaderPriority != nil {
+		flags |= http2.FlagHeadersPriority
+		payload = appendH2Priority(payload, *c.fp.HeaderPriority)
+	}
+
+	first := min(len(block), h2MaxFrameSize-len(payload))
+	payload = append(payload, block[:first]...)
+	block = block[first:]
+	if len(block) == 0 {
+		flags |= http2.FlagHeadersEndHeaders
+	}
+	out = appendH2Frame(out, http2.FrameHeaders, flags, c.hstream, payload)
+
+	for len(block) > 0 {
+		n := min(len(block), h2MaxFrameSize)
+		var flags http2.Flags
+		if n == len(block) {
+			flags = http2.FlagContinuationEndHeaders
+		}
+		out = appendH2Frame(out, http2.FrameContinuation, flags, c.hstream, block[:n])
+		block = block[n:]
+	}
+
+	return out, nil
+}
+
+func (c *h2FingerprintConn) Read(p []byte) (int, error) {
+	c.readOnce.Do(func() {
+		go c.readLoop()
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.ready.Len() == 0 && c.rerr == nil {
+		c.cond.Wait()
+	}
+	if c.ready.Len() > 0 {
+		return c.ready.Read(p)
+	}
+	return 0, c.rerr
+}
+
+// readLoop 按帧读取服务端数据
+func (c *h2FingerprintConn) readLoop() {
+	br := bufio.NewReader(c.Conn)
+	for {
+		header := make([]byte, h2FrameHeaderLen)
+		_, err := io.ReadFull(br, header)
+		var frame []byte
+		if err == nil {
+			length := int(header[0])<<16 | int(header[1])<<8 | int(header[2])
+			frame = make([]byte, h2FrameHeaderLen+length)
+			copy(frame, header)
+			_, err = io.ReadFull(br, frame[h2FrameHeaderLen:])
+		}
+
+		c.mu.Lock()
+		if err != nil {
+			c.rerr = err
+			c.cond.Broadcast()
+			c.mu.Unlock()
+			return
+		}
+		c.receive(frame)
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	}
+}
+
+// receive 处理服务端的帧 调用时持有锁
+func (c *h2FingerprintConn) receive(frame []byte) {
+	typ := http2.FrameType(frame[3])
+	flags := http2.Flags(frame[4])
+	streamID := binary.BigEndian.Uint32(frame[5:9]) & (1<<31 - 1)
+
+	if typ == http2.FrameSettings && !flags.Has(http2.FlagSettingsAck) {
+		payload := frame[h2FrameHeaderLen:]
+		for i := 0; i+6 <= len(payload); i += 6 {
+			if http2.SettingID(binary.BigEndian.Uint16(payload[i:])) == http2.SettingHeaderTableSize {
+				c.peerTableSize = binary.BigEndian.Uint32(payload[i+2:])
+				c.peerTableDirty = true
+			}
+		}
+	}
+
+	if streamID != 0 && (len(c.held[streamID]) > 0 || typ == http2.FrameData && !c.fits(streamID, frame)) {
+		c.held[streamID] = append(c.held[streamID], frame)
+		return
+	}
+
+	c.deliver(frame)
+}
+
+func (c *h2FingerprintConn) fits(streamID uint32, frame []byte) bool {
+	length := int64(len(frame) - h2FrameHeaderLen)
+	if length > c.connAvail {
+		return false
+	}
+	if c.resetStreams[streamID] {
+		return true
+	}
+
+	avail, ok := c.streamAvail[streamID]
+	if !ok {
+		avail = h2TransportStreamFlow
+	}
+	return length <= avail
+}
+
+func (c *h2FingerprintConn) deliver(frame []byte) {
+	c.ready.Write(frame)
+
+	typ := http2.FrameType(frame[3])
+	flags := http2.Flags(frame[4])
+	streamID := binary.BigEndian.Uint32(frame[5:9]) & (1<<31 - 1)
+
+	if typ == http2.FrameData {
+		length := int64(len(frame) - h2FrameHeaderLen)
+		c.connAvail -= length
+		if !c.resetStreams[streamID] {
+			avail, ok := c.streamAvail[streamID]
+			if !ok {
+				avail = h2TransportStreamFlow
+			}
+			c.streamAvail[streamID] = avail - length
+		}
+	}
+
+	endStream := (typ == http2.FrameData || typ == http2.FrameHeaders) && flags.Has(http2.FlagDataEndStream)
+	if endStream || typ == http2.FrameRSTStream {
+		delete(c.streamAvail, streamID)
+		delete(c.resetStreams, streamID)
+	}
+}
+
+// release 发送暂存的帧 直到窗口不足
+func (c *h2FingerprintConn) release(streamID uint32) {
+	frames := c.held[streamID]
+	for len(frames) > 0 {
+		if http2.FrameType(frames[0][3]) == http2.FrameData && !c.fits(streamID, frames[0]) {
+			break
+		}
+		c.deliver(frames[0])
+		frames = frames[1:]
+	}
+
+	if len(frames) == 0 {
+		delete(c.held, streamID)
+	} else {
+		c.held[streamID] = frames
+	}
+}
+
+// addWindow http2.Transport 发送了 WINDOW_UPDATE
+func (c *h2FingerprintConn) addWindow(streamID, increment uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if streamID == 0 {
+		c.connAvail += int64(increment)
+		for id := range c.held {
+			c.release(id)
+		}
+	} else if avail, ok := c.streamAvail[streamID]; ok {
+		c.streamAvail[streamID] = avail + int64(increment)
+		c.release(streamID)
+	}
+	c.cond.Broadcast()
+}
+
+// resetStream http2.Transport 取消了请求 不再限制这个流
+func (c *h2FingerprintConn) resetStream(streamID uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.resetStreams[streamID] = true
+	delete(c.streamAvail, streamID)
+	c.release(streamID)
+	c.cond.Broadcast()
+}
+
+func appendH2Frame(out []byte, typ http2.FrameType, flags http2.Flags, streamID uint32, payload []byte) []byte {
+	length := len(payload)
+	out = append(out, byte(length>>16), byte(length>>8), byte(length), byte(typ), byte(flags))
+	out = binary.BigEndian.AppendUint32(out, streamID&(1<<31-1))
+	return append(out, payload...)
+}
+
+func appendH2Priority(out []byte, p http2.PriorityParam) []byte {
+	dep := p.StreamDep & (1<<31 - 1)
+	if p.Exclusive {
+		dep |= 1 << 31
+	}
+	out = binary.BigEndian.AppendUint32(out, dep)
+	return append(out, p.Weight)
+}
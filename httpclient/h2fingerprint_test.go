@@ -0,0 +1,213 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+const chromeAkamai = "1:65536;2:0;4:6291456;6:262144|15663105|0|m,a,s,p"
+
+func TestParseAkamaiFingerprint(t *testing.T) {
+	for _, fingerprint := range []string{
+		chromeAkamai,
+		"1:65536;4:131072;5:16384|12517377|3:0:0:201,5:0:0:101,7:0:0:1,9:0:7:1,11:0:3:1,13:0:0:241|m,p,a,s",
+		"2:0;4:4194304;6:10485760|00|0|a,m,p,s",
+	} {
+		fp, err := ParseAkamaiFingerprint(fingerprint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fp.String(); got != fingerprint {
+			t.Errorf("String() = %s, want %s", got, fingerprint)
+		}
+	}
+
+	for _, fingerprint := range []string{"1:1|0|0", "2:1|00|0|m,a,s,p", "1:1|00|0|m,x", "1:1|00|3:0:0:0|m"} {
+		if _, err := ParseAkamaiFingerprint(fingerprint); err == nil {
+			t.Errorf("ParseAkamaiFingerprint(%q) want error", fingerprint)
+		}
+	}
+
+	if got, _ := NewTransport().AkamaiFingerprint(); got != defaultAkamaiFingerprint {
+		t.Fatalf("AkamaiFingerprint() = %s", got)
+	}
+}
+
+func TestJA4(t *testing.T) {
+	tr := NewTransport(WithTlsConnOptJA3Hints(chromeJA3, &JA3Hints{GREASE: true}))
+	if got, err := tr.JA4(); err != nil || got != "t13d1516h2_8daaf6152771_e5627efa2ab1" {
+		t.Fatalf("JA4() = %s, err = %v", got, err)
+	}
+
+	tr = NewTransport(WithTlsConnOptJA3("771,49199,0-10-11,23,0"))
+	if got, err := tr.JA4(); err != nil || !strings.HasPrefix(got, "t12d0103"+"00_") {
+		t.Fatalf("JA4() = %s, err = %v", got, err)
+	}
+
+	if _, err := NewTransport(WithTlsConnOptJA3("invalid")).JA4(); err == nil {
+		t.Fatal("JA4() with an invalid ja3 should fail")
+	}
+
+	for proto, want := range map[string]string{"h2": "h2", "http/1.1": "h1", "\xab\xcd": "ad", "": "00"} {
+		if got := ja4ALPN(proto); got != want {
+			t.Errorf("ja4ALPN(%q) = %s, want %s", proto, got, want)
+		}
+	}
+}
+
+// recordConn 记录客户端写出的数据
+type recordConn struct {
+	net.Conn
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (c *recordConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	c.buf.Write(p)
+	c.mu.Unlock()
+	return c.Conn.Write(p)
+}
+
+func TestH2FingerprintConn(t *testing.T) {
+	body := bytes.Repeat([]byte("0123456789abcdef"), 1<<20) // 16MB 超过 http2.Transport 的流窗口
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		if len(data) > 0 {
+			_, _ = w.Write(data)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	s.EnableHTTP2 = true
+	s.StartTLS()
+	defer s.Close()
+
+	fp, err := ParseAkamaiFingerprint(chromeAkamai)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp.Priorities = []HTTP2Priority{{StreamID: 3, PriorityParam: http2.PriorityParam{StreamDep: 0, Weight: 200}}}
+	fp.HeaderPriority = &http2.PriorityParam{Exclusive: true, Weight: 255}
+	fp.HeaderOrder = []string{"user-agent", "accept-encoding"}
+
+	var record *recordConn
+	tr := &http2.Transport{}
+	fp.configure(tr)
+	tr.DialTLSContext = func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+		conn, err := tls.Dial(network, addr, &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2"}})
+		if err != nil {
+			return nil, err
+		}
+		record = &recordConn{Conn: conn}
+		return newH2FingerprintConn(record, fp), nil
+	}
+	client := &http.Client{Transport: tr}
+
+	resp, err := client.Get(s.URL + "/download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || !bytes.Equal(data, body) {
+		t.Fatalf("download = %d bytes, err = %v", len(data), err)
+	}
+
+	post := strings.Repeat("x", 100000)
+	resp, err = client.Post(s.URL+"/upload", "text/plain", strings.NewReader(post))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(data) != post {
+		t.Fatalf("upload echo = %d bytes", len(data))
+	}
+
+	record.mu.Lock()
+	written := record.buf.Bytes()
+	record.mu.Unlock()
+	if !bytes.HasPrefix(written, []byte(http2.ClientPreface)) {
+		t.Fatal("missing client preface")
+	}
+
+	framer := http2.NewFramer(nil, bytes.NewReader(written[len(http2.ClientPreface):]))
+	var (
+		frames  []string
+		pseudo  []string
+		regular []string
+		headers int
+	)
+	dec := hpack.NewDecoder(4096, nil)
+	for {
+		f, err := framer.ReadFrame()
+		if err != nil {
+			break
+		}
+		switch f := f.(type) {
+		case *http2.SettingsFrame:
+			if !f.IsAck() {
+				var settings []string
+				_ = f.ForeachSetting(func(s http2.Setting) error {
+					settings = append(settings, s.ID.String())
+					return nil
+				})
+				frames = append(frames, "SETTINGS "+strings.Join(settings, ","))
+			}
+		case *http2.WindowUpdateFrame:
+			if len(frames) == 1 {
+				frames = append(frames, fmt.Sprintf("WINDOW_UPDATE %d", f.Increment))
+			}
+		case *http2.PriorityFrame:
+			frames = append(frames, fmt.Sprintf("PRIORITY %d %d", f.StreamID, f.Weight))
+		case *http2.HeadersFrame:
+			headers++
+			if headers == 1 {
+				frames = append(frames, fmt.Sprintf("HEADERS %d", f.Priority.Weight))
+			}
+			fields, err := dec.DecodeFull(f.HeaderBlockFragment())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, field := range fields {
+				if headers == 1 && field.IsPseudo() {
+					pseudo = append(pseudo, field.Name)
+				} else if headers == 1 {
+					regular = append(regular, field.Name)
+				}
+			}
+		}
+	}
+
+	want := []string{
+		"SETTINGS HEADER_TABLE_SIZE,ENABLE_PUSH,INITIAL_WINDOW_SIZE,MAX_HEADER_LIST_SIZE",
+		"WINDOW_UPDATE 15663105",
+		"PRIORITY 3 200",
+		"HEADERS 255",
+	}
+	if strings.Join(frames, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("frames:\n%s\nwant:\n%s", strings.Join(frames, "\n"), strings.Join(want, "\n"))
+	}
+	if strings.Join(pseudo, ",") != ":method,:authority,:scheme,:path" {
+		t.Fatalf("pseudo header order = %v", pseudo)
+	}
+	if strings.Join(regular, ",") != "user-agent,accept-encoding" {
+		t.Fatalf("header order = %v", regular)
+	}
+	if headers != 2 {
+		t.Fatalf("headers frames = %d, want 2", headers)
+	}
+}
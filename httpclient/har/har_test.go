@@ -0,0 +1,210 @@
+package har
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/bin":
+			_, _ = w.Write([]byte{0xff, 0x00, 0xfe})
+		default:
+			_, _ = w.Write([]byte(r.Method + " " + r.URL.Query().Get("q") + " " + string(body)))
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func do(t *testing.T, client *http.Client, method, url, body string) string {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.AddCookie(&http.Cookie{Name: "uid", Value: "1"})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	s := newTestServer(t)
+	recorder := NewRecorder(s.Client().Transport)
+	client := &http.Client{Transport: recorder}
+
+	want := []string{
+		do(t, client, http.MethodGet, s.URL+"/search?q=go&page=1", ""),
+		do(t, client, http.MethodPost, s.URL+"/submit", "name=ava"),
+		do(t, client, http.MethodGet, s.URL+"/bin", ""),
+	}
+
+	h := recorder.HAR()
+	if len(h.Log.Entries) != 3 {
+		t.Fatalf("entries = %d, want 3", len(h.Log.Entries))
+	}
+
+	entry := h.Log.Entries[0]
+	if entry.TLS == nil || entry.TLS.Version == "" {
+		t.Fatalf("tls = %+v, want tls info", entry.TLS)
+	}
+	if len(entry.Request.Cookies) != 1 || entry.Request.Cookies[0].Name != "uid" {
+		t.Fatalf("request cookies = %+v", entry.Request.Cookies)
+	}
+	if len(entry.Response.Cookies) != 1 || entry.Response.Cookies[0].Value != "abc" {
+		t.Fatalf("response cookies = %+v", entry.Response.Cookies)
+	}
+	if len(entry.Request.QueryString) != 2 {
+		t.Fatalf("query string = %+v", entry.Request.QueryString)
+	}
+	if entry.Timings == nil || entry.Time <= 0 {
+		t.Fatalf("timings = %+v, time = %f", entry.Timings, entry.Time)
+	}
+	if h.Log.Entries[1].Request.PostData.Text != "name=ava" {
+		t.Fatalf("post data = %+v", h.Log.Entries[1].Request.PostData)
+	}
+	if h.Log.Entries[2].Response.Content.Encoding != "base64" {
+		t.Fatalf("binary content = %+v", h.Log.Entries[2].Response.Content)
+	}
+
+	filename := filepath.Join(t.TempDir(), "session.har")
+	if err := recorder.SaveFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	replayer, err := NewReplayerFromFile(filename, WithMatchBody(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = &http.Client{Transport: replayer}
+
+	got := []string{
+		do(t, client, http.MethodGet, s.URL+"/search?page=1&q=go", ""),
+		do(t, client, http.MethodPost, s.URL+"/submit", "name=ava"),
+		do(t, client, http.MethodGet, s.URL+"/bin", ""),
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("replay %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, s.URL+"/submit", strings.NewReader("name=bob"))
+	if _, err = client.Do(req); !errors.Is(err, ErrNoMatch) {
+		t.Fatalf("err = %v, want ErrNoMatch", err)
+	}
+}
+
+func TestReplaySequence(t *testing.T) {
+	h := New()
+	for _, text := range []string{"first", "second"} {
+		h.Log.Entries = append(h.Log.Entries, &Entry{
+			Request:  &Request{Method: http.MethodGet, URL: "https://example.com/"},
+			Response: &Response{Status: http.StatusOK, Content: &Content{Text: text}},
+		})
+	}
+
+	client := &http.Client{Transport: NewReplayer(h)}
+	for _, want := range []string{"first", "second", "second"} {
+		if got := do(t, client, http.MethodGet, "https://example.com/", ""); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestRecordStreamAndError(t *testing.T) {
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("first;"))
+		w.(http.Flusher).Flush()
+		<-release
+		_, _ = w.Write([]byte("second"))
+	}))
+	defer s.Close()
+
+	recorder := NewRecorder(s.Client().Transport)
+	client := &http.Client{Transport: recorder}
+	resp, err := client.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 不需要等待整个 body 就能读取
+	buf := make([]byte, len("first;"))
+	if _, err = io.ReadFull(resp.Body, buf); err != nil || string(buf) != "first;" {
+		t.Fatalf("first chunk = %q, err = %v", buf, err)
+	}
+	if n := len(recorder.HAR().Log.Entries); n != 0 {
+		t.Fatalf("entries = %d before the body is read", n)
+	}
+	close(release)
+	rest, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(rest) != "second" {
+		t.Fatalf("rest = %q", rest)
+	}
+
+	s.Close()
+	if _, err = client.Get(s.URL + "/down"); err == nil {
+		t.Fatal("request to closed server should fail")
+	}
+
+	h := recorder.HAR()
+	if len(h.Log.Entries) != 2 {
+		t.Fatalf("entries = %d, want 2", len(h.Log.Entries))
+	}
+	if text := h.Log.Entries[0].Response.Content.Text; text != "first;second" {
+		t.Fatalf("content = %q", text)
+	}
+	failed := h.Log.Entries[1]
+	if failed.Response.Status != 0 || failed.Response.Error == "" || failed.Request.URL != s.URL+"/down" {
+		t.Fatalf("failed entry = %+v, request = %+v", failed.Response, failed.Request)
+	}
+
+	client = &http.Client{Transport: NewReplayer(h)}
+	if _, err = client.Get(s.URL + "/down"); !errors.Is(err, ErrRecordedFailure) {
+		t.Fatalf("err = %v, want ErrRecordedFailure", err)
+	}
+}
+
+func TestRecordMaxBodySize(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("0123456789"))
+	}))
+	defer s.Close()
+
+	recorder := NewRecorder(s.Client().Transport, WithMaxBodySize(4))
+	client := &http.Client{Transport: recorder}
+	resp, err := client.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "0123456789" {
+		t.Fatalf("body = %q", body)
+	}
+
+	content := recorder.HAR().Log.Entries[0].Response.Content
+	if content.Text != "0123" || content.Size != 10 || !strings.Contains(content.Comment, "truncated") {
+		t.Fatalf("content = %+v", content)
+	}
+}
@@ -0,0 +1,419 @@
+package har
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Recorder 记录经过的每个请求 实现 http.RoundTripper
+//
+//	recorder := har.NewRecorder(http.DefaultTransport)
+//	client := httpclient.NewClient(httpclient.WithTransport(recorder))
+//	...
+//	recorder.HAR().SaveFile("session.har")
+type Recorder struct {
+	next        http.RoundTripper
+	maxBodySize int64
+
+	mu  sync.Mutex
+	har *HAR
+}
+
+// 默认每个响应最多记录的 body
+const defaultMaxBodySize = 10 << 20
+
+type RecorderOption func(*Recorder)
+
+// WithMaxBodySize 每个响应最多记录 n 字节的 body 默认10MB <=0 不限制
+// 超过的部分不记录 content.comment 中注明截断 content.size 为实际大小
+func WithMaxBodySize(n int64) RecorderOption {
+	return func(r *Recorder) {
+		r.maxBodySize = n
+	}
+}
+
+// NewRecorder 包装 next next 为空时使用 http.DefaultTransport
+func NewRecorder(next http.RoundTripper, opts ...RecorderOption) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	r := &Recorder{next: next, maxBodySize: defaultMaxBodySize, har: New()}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// HAR 返回已记录的请求 返回的是副本
+func (r *Recorder) HAR() *HAR {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h := New()
+	h.Log.Entries = append(h.Log.Entries, r.har.Log.Entries...)
+	return h
+}
+
+// Reset 清空已记录的请求
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.har.Log.Entries = []*Entry{}
+	r.mu.Unlock()
+}
+
+// SaveFile 把已记录的请求写入文件
+func (r *Recorder) SaveFile(filename string) error {
+	return r.HAR().SaveFile(filename)
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		trace   timingTrace
+		entry   = &Entry{StartedDateTime: time.Now()}
+		start   = entry.StartedDateTime
+		traceFn = trace.clientTrace(entry)
+	)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), traceFn))
+
+	entry.Request = newRequest(req, reqBody)
+	finish := func(response *Response) {
+		end := time.Now()
+		entry.Response = response
+		entry.Timings = trace.timings(start, end)
+		entry.Time = ms(end.Sub(start))
+
+		r.mu.Lock()
+		r.har.Log.Entries = append(r.har.Log.Entries, entry)
+		r.mu.Unlock()
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		// 失败的请求也记录 status 为 0
+		finish(errorResponse(err))
+		return resp, err
+	}
+
+	entry.TLS = newTLS(resp.TLS)
+	// body 读取完或关闭时才记录 调用方读取的同时复制 最多复制 maxBodySize
+	resp.Body = &recordingBody{
+		ReadCloser: resp.Body,
+		max:        r.maxBodySize,
+		finish: func(body []byte, size int64) {
+			response := newResponse(resp, body)
+			if size > int64(len(body)) {
+				response.Content.Size, response.BodySize = size, size
+				response.Content.Comment = fmt.Sprintf("truncated: recorded %d of %d bytes", len(body), size)
+			}
+			finish(response)
+		},
+	}
+	return resp, nil
+}
+
+// recordingBody 读取 body 的同时复制一份 读到 EOF 或关闭时回调一次
+// 超过 max 的部分只计数不复制 size 为读取的总字节数
+type recordingBody struct {
+	io.ReadCloser
+	max    int64 // <=0 不限制
+	finish func(body []byte, size int64)
+
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	size int64
+	once sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.mu.Lock()
+	data := p[:n]
+	if b.max > 0 {
+		data = data[:max(0, min(int64(n), b.max-int64(b.buf.Len())))]
+	}
+	b.buf.Write(data)
+	b.size += int64(n)
+	b.mu.Unlock()
+	if err == io.EOF {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+	return err
+}
+
+func (b *recordingBody) done() {
+	b.once.Do(func() {
+		b.mu.Lock()
+		body, size := bytes.Clone(b.buf.Bytes()), b.size
+		b.buf.Reset()
+		b.mu.Unlock()
+		b.finish(body, size)
+	})
+}
+
+// readRequestBody 读取请求body 并让请求可以继续发送
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+func newRequest(req *http.Request, body []byte) *Request {
+	r := &Request{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     []Cookie{},
+		Headers:     headerPairs(req.Header),
+		QueryString: []NameValue{},
+		HeadersSize: -1,
+		BodySize:    int64(len(body)),
+	}
+	if r.HTTPVersion == "" {
+		r.HTTPVersion = "HTTP/1.1"
+	}
+
+	for _, c := range req.Cookies() {
+		r.Cookies = append(r.Cookies, newCookie(c))
+	}
+
+	query := req.URL.Query()
+	for _, key := range sortedKeys(query) {
+		for _, value := range query[key] {
+			r.QueryString = append(r.QueryString, NameValue{Name: key, Value: value})
+		}
+	}
+
+	if body != nil {
+		text, encoding := encodeBody(body)
+		r.PostData = &PostData{
+			MimeType: req.Header.Get("Content-Type"),
+			Params:   []NameValue{},
+			Text:     text,
+			Encoding: encoding,
+		}
+	}
+
+	return r
+}
+
+func newResponse(resp *http.Response, body []byte) *Response {
+	text, encoding := encodeBody(body)
+	r := &Response{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Cookies:     []Cookie{},
+		Headers:     headerPairs(resp.Header),
+		Content: &Content{
+			Size:     int64(len(body)),
+			MimeType: resp.Header.Get("Content-Type"),
+			Text:     text,
+			Encoding: encoding,
+		},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    int64(len(body)),
+	}
+
+	for _, c := range resp.Cookies() {
+		r.Cookies = append(r.Cookies, newCookie(c))
+	}
+
+	return r
+}
+
+func errorResponse(err error) *Response {
+	return &Response{
+		Cookies:     []Cookie{},
+		Headers:     []NameValue{},
+		Content:     &Content{},
+		HeadersSize: -1,
+		BodySize:    -1,
+		Error:       err.Error(),
+	}
+}
+
+func newCookie(c *http.Cookie) Cookie {
+	cookie := Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Path:     c.Path,
+		Domain:   c.Domain,
+		HTTPOnly: c.HttpOnly,
+		Secure:   c.Secure,
+	}
+	if !c.Expires.IsZero() {
+		expires := c.Expires
+		cookie.Expires = &expires
+	}
+	return cookie
+}
+
+func newTLS(state *tls.ConnectionState) *TLS {
+	if state == nil {
+		return nil
+	}
+
+	t := &TLS{
+		Version:            tls.VersionName(state.Version),
+		CipherSuite:        tls.CipherSuiteName(state.CipherSuite),
+		ServerName:         state.ServerName,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+		DidResume:          state.DidResume,
+	}
+	for _, cert := range state.PeerCertificates {
+		t.PeerCertificates = append(t.PeerCertificates, cert.Subject.String())
+	}
+
+	return t
+}
+
+func headerPairs(header http.Header) []NameValue {
+	pairs := make([]NameValue, 0, len(header))
+	for _, key := range sortedKeys(header) {
+		for _, value := range header[key] {
+			pairs = append(pairs, NameValue{Name: key, Value: value})
+		}
+	}
+	return pairs
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// encodeBody 文本直接保存 二进制数据保存为 base64
+func encodeBody(body []byte) (text, encoding string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// decodeBody encodeBody 的逆操作
+func decodeBody(text, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(text)
+	}
+	return []byte(text), nil
+}
+
+// timingTrace 通过 httptrace 统计各阶段耗时
+type timingTrace struct {
+	mu                               sync.Mutex
+	dnsStart, dnsDone                time.Time
+	connectStart, connectDone        time.Time
+	tlsStart, tlsDone                time.Time
+	gotConn, wroteRequest, firstByte time.Time
+}
+
+func (t *timingTrace) set(field *time.Time) {
+	t.mu.Lock()
+	*field = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *timingTrace) clientTrace(entry *Entry) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { t.set(&t.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { t.set(&t.dnsDone) },
+		ConnectStart:      func(string, string) { t.set(&t.connectStart) },
+		ConnectDone:       func(string, string, error) { t.set(&t.connectDone) },
+		TLSHandshakeStart: func() { t.set(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.set(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.set(&t.gotConn)
+			t.mu.Lock()
+			// connection 使用本地端口区分不同的 TCP 链接
+			entry.ServerIPAddress, _, _ = net.SplitHostPort(info.Conn.RemoteAddr().String())
+			_, entry.Connection, _ = net.SplitHostPort(info.Conn.LocalAddr().String())
+			t.mu.Unlock()
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.wroteRequest) },
+		GotFirstResponseByte: func() { t.set(&t.firstByte) },
+	}
+}
+
+func (t *timingTrace) timings(start, end time.Time) *Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timings := &Timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	if !t.dnsStart.IsZero() && !t.dnsDone.IsZero() {
+		timings.DNS = ms(t.dnsDone.Sub(t.dnsStart))
+	}
+	if !t.connectStart.IsZero() && !t.connectDone.IsZero() {
+		timings.Connect = ms(t.connectDone.Sub(t.connectStart))
+	}
+	if !t.tlsStart.IsZero() && !t.tlsDone.IsZero() {
+		timings.SSL = ms(t.tlsDone.Sub(t.tlsStart))
+		// HAR 规范中 connect 包含 ssl
+		if timings.Connect >= 0 {
+			timings.Connect += timings.SSL
+		}
+	}
+
+	sendStart := t.gotConn
+	if sendStart.IsZero() {
+		sendStart = start
+	}
+	if !t.wroteRequest.IsZero() {
+		timings.Send = ms(t.wroteRequest.Sub(sendStart))
+		if !t.firstByte.IsZero() {
+			timings.Wait = ms(t.firstByte.Sub(t.wroteRequest))
+		}
+	}
+	if !t.firstByte.IsZero() {
+		timings.Receive = ms(end.Sub(t.firstByte))
+	}
+
+	return timings
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,246 @@
+package har
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNoMatch 回放时没有匹配的记录
+var ErrNoMatch = errors.New("har: no matching entry")
+
+// ErrRecordedFailure 匹配的记录是一个失败的请求 回放时返回这个错误
+var ErrRecordedFailure = errors.New("har: recorded request failed")
+
+// Matcher 自定义匹配规则 在内置规则匹配后调用
+type Matcher func(req *http.Request, body []byte, entry *Entry) bool
+
+type ReplayOption func(*Replayer)
+
+// WithMatchMethod 是否匹配请求方法 默认匹配
+func WithMatchMethod(match bool) ReplayOption {
+	return func(r *Replayer) {
+		r.matchMethod = match
+	}
+}
+
+// WithMatchURL 是否匹配 scheme、host 和 path 默认匹配
+func WithMatchURL(match bool) ReplayOption {
+	return func(r *Replayer) {
+		r.matchURL = match
+	}
+}
+
+// WithMatchQuery 是否匹配查询参数 忽略参数顺序 默认匹配
+func WithMatchQuery(match bool) ReplayOption {
+	return func(r *Replayer) {
+		r.matchQuery = match
+	}
+}
+
+// WithMatchBody 是否匹配请求body 默认不匹配
+func WithMatchBody(match bool) ReplayOption {
+	return func(r *Replayer) {
+		r.matchBody = match
+	}
+}
+
+// WithMatcher 添加自定义匹配规则
+func WithMatcher(matcher Matcher) ReplayOption {
+	return func(r *Replayer) {
+		r.matchers = append(r.matchers, matcher)
+	}
+}
+
+// WithFallback 没有匹配的记录时使用 rt 发送请求 默认返回 ErrNoMatch
+func WithFallback(rt http.RoundTripper) ReplayOption {
+	return func(r *Replayer) {
+		r.fallback = rt
+	}
+}
+
+// Replayer 从 HAR 回放响应 实现 http.RoundTripper
+// 同一个请求有多条记录时按记录顺序依次返回 全部返回后重复返回最后一条
+type Replayer struct {
+	entries     []*Entry
+	matchMethod bool
+	matchURL    bool
+	matchQuery  bool
+	matchBody   bool
+	matchers    []Matcher
+	fallback    http.RoundTripper
+
+	mu   sync.Mutex
+	used map[int]bool
+}
+
+func NewReplayer(h *HAR, opts ...ReplayOption) *Replayer {
+	r := &Replayer{
+		matchMethod: true,
+		matchURL:    true,
+		matchQuery:  true,
+		used:        make(map[int]bool),
+	}
+	if h != nil && h.Log != nil {
+		r.entries = h.Log.Entries
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// NewReplayerFromFile 从 HAR 文件创建 Replayer
+func NewReplayerFromFile(filename string, opts ...ReplayOption) (*Replayer, error) {
+	h, err := LoadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReplayer(h, opts...), nil
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := r.match(req, body)
+	if entry == nil {
+		if r.fallback != nil {
+			return r.fallback.RoundTrip(req)
+		}
+		return nil, fmt.Errorf("%w: %s %s", ErrNoMatch, req.Method, req.URL)
+	}
+
+	return newHTTPResponse(req, entry)
+}
+
+func (r *Replayer) match(req *http.Request, body []byte) *Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last := -1
+	for i, entry := range r.entries {
+		if !r.matchEntry(req, body, entry) {
+			continue
+		}
+
+		if !r.used[i] {
+			r.used[i] = true
+			return entry
+		}
+		last = i
+	}
+
+	if last < 0 {
+		return nil
+	}
+
+	return r.entries[last]
+}
+
+func (r *Replayer) matchEntry(req *http.Request, body []byte, entry *Entry) bool {
+	if entry.Request == nil || entry.Response == nil {
+		return false
+	}
+
+	if r.matchMethod && !strings.EqualFold(req.Method, entry.Request.Method) {
+		return false
+	}
+
+	if r.matchURL || r.matchQuery {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			return false
+		}
+
+		if r.matchURL && (!strings.EqualFold(u.Scheme, req.URL.Scheme) || !strings.EqualFold(u.Host, req.URL.Host) ||
+			u.EscapedPath() != req.URL.EscapedPath()) {
+			return false
+		}
+
+		if r.matchQuery && !reflect.DeepEqual(normalizeQuery(u.Query()), normalizeQuery(req.URL.Query())) {
+			return false
+		}
+	}
+
+	if r.matchBody {
+		var recorded []byte
+		if entry.Request.PostData != nil {
+			data, err := decodeBody(entry.Request.PostData.Text, entry.Request.PostData.Encoding)
+			if err != nil {
+				return false
+			}
+			recorded = data
+		}
+
+		if !bytes.Equal(recorded, body) {
+			return false
+		}
+	}
+
+	for _, matcher := range r.matchers {
+		if !matcher(req, body, entry) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func normalizeQuery(query url.Values) url.Values {
+	if len(query) == 0 {
+		return nil
+	}
+	return query
+}
+
+func newHTTPResponse(req *http.Request, entry *Entry) (*http.Response, error) {
+	if entry.Response.Error != "" {
+		return nil, fmt.Errorf("%w: %s", ErrRecordedFailure, entry.Response.Error)
+	}
+
+	var body []byte
+	if content := entry.Response.Content; content != nil {
+		data, err := decodeBody(content.Text, content.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		body = data
+	}
+
+	resp := &http.Response{
+		Status:        strconv.Itoa(entry.Response.Status) + " " + entry.Response.StatusText,
+		StatusCode:    entry.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+
+	if major, minor, ok := http.ParseHTTPVersion(entry.Response.HTTPVersion); ok {
+		resp.Proto, resp.ProtoMajor, resp.ProtoMinor = entry.Response.HTTPVersion, major, minor
+	}
+
+	for _, header := range entry.Response.Headers {
+		resp.Header.Add(header.Name, header.Value)
+	}
+	// 记录的body已经是完整内容 长度以实际body为准
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	return resp, nil
+}
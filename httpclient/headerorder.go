@@ -0,0 +1,289 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/net/http2/hpack"
+)
+
+// Transport 通过内部头部把顺序传给底层连接 写出时会被去掉
+const (
+	headerOrderKey       = "X-Httpclient-Header-Order"
+	pseudoHeaderOrderKey = "X-Httpclient-Pseudo-Header-Order"
+)
+
+type headerOrderContextKey struct{}
+
+type headerOrderValue struct {
+	header []string
+	pseudo []string
+}
+
+// NewHeaderOrderContext 指定请求的头部顺序 pseudoHeaderOrder 只对http2有效 如 :method :authority :scheme :path
+// 只有 httpclient.Transport 支持 不在顺序中的头部放在后面
+func NewHeaderOrderContext(ctx context.Context, headerOrder, pseudoHeaderOrder []string) context.Context {
+	return context.WithValue(ctx, headerOrderContextKey{}, &headerOrderValue{header: headerOrder, pseudo: pseudoHeaderOrder})
+}
+
+// setHeaderOrder 把 context 或 Profile 中的头部顺序写入请求 req 必须是复制的请求
+func (t *Transport) setHeaderOrder(req *http.Request) {
+	var order, pseudo []string
+	if o, ok := req.Context().Value(headerOrderContextKey{}).(*headerOrderValue); ok {
+		order, pseudo = o.header, o.pseudo
+	}
+	if len(order) == 0 && t.profile != nil {
+		order = t.profile.HeaderOrder
+	}
+
+	if len(order) > 0 {
+		req.Header.Set(headerOrderKey, strings.Join(order, ","))
+	}
+	if len(pseudo) > 0 {
+		req.Header.Set(pseudoHeaderOrderKey, strings.Join(pseudo, ","))
+	}
+}
+
+func splitHeaderOrder(value string) []string {
+	var order []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			order = append(order, name)
+		}
+	}
+	return order
+}
+
+// extractHeaderOrder 去掉http2头部中的顺序标记 返回头部顺序和伪头部顺序
+func extractHeaderOrder(fields []hpack.HeaderField) ([]hpack.HeaderField, []string, []string) {
+	var order, pseudo []string
+	kept := fields[:0]
+	for _, f := range fields {
+		switch {
+		case strings.EqualFold(f.Name, headerOrderKey):
+			order = splitHeaderOrder(f.Value)
+		case strings.EqualFold(f.Name, pseudoHeaderOrderKey):
+			pseudo = splitHeaderOrder(f.Value)
+		default:
+			kept = append(kept, f)
+		}
+	}
+	return kept, order, pseudo
+}
+
+// http1.1 请求写入的状态
+const (
+	h1StateHeader = iota
+	h1StateBody
+	h1StateChunkSize
+	h1StateChunkData
+	h1StateTrailer
+	h1StatePassthrough // 升级成功(101)后连接上不再是http1.1 原样写出
+)
+
+// 读取 Upgrade 请求的响应状态行时 最多缓存的字节数
+const maxUpgradeStatusLine = 1024
+
+// h1HeaderOrderConn 按顺序标记重排http1.1请求的头部 没有顺序标记的请求原样写出
+// 需要跟踪 body 的边界 保证 keep-alive 连接上的下一个请求也能找到头部
+// Upgrade 请求的响应为 101 时切换为原样写出 拒绝升级时连接继续按http1.1处理
+type h1HeaderOrderConn struct {
+	net.Conn
+
+	mu     sync.Mutex // Write 和 Read 在不同的 goroutine
+	state  int
+	buf    []byte // 未完整的请求头或 chunk 行
+	remain int64  // body 或 chunk 剩余的字节数
+
+	upgrading atomic.Bool // 发送了 Upgrade 请求 等待响应的状态行
+	status    []byte      // 未完整的响应状态行
+}
+
+func newH1HeaderOrderConn(conn net.Conn) net.Conn {
+	return &h1HeaderOrderConn{Conn: conn}
+}
+
+func (c *h1HeaderOrderConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 && c.upgrading.Load() {
+		c.mu.Lock()
+		c.readStatus(p[:n])
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+// readStatus 读到 Upgrade 请求的响应状态行后 101 切换为原样写出
+func (c *h1HeaderOrderConn) readStatus(p []byte) {
+	c.status = append(c.status, p...)
+	i := bytes.IndexByte(c.status, '\n')
+	if i < 0 && len(c.status) < maxUpgradeStatusLine {
+		return
+	}
+	if i >= 0 {
+		// HTTP/1.1 101 Switching Protocols
+		fields := strings.Fields(string(c.status[:i]))
+		if len(fields) >= 2 && fields[1] == "101" {
+			c.state = h1StatePassthrough
+		}
+	}
+	c.status = nil
+	c.upgrading.Store(false)
+}
+
+func (c *h1HeaderOrderConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == h1StatePassthrough {
+		return c.Conn.Write(p)
+	}
+
+	var out []byte
+	rest := p
+	for len(rest) > 0 {
+		switch c.state {
+		case h1StateHeader:
+			c.buf = append(c.buf, rest...)
+			i := bytes.Index(c.buf, []byte("\r\n\r\n"))
+			if i < 0 {
+				rest = nil
+				continue
+			}
+			rest = append([]byte(nil), c.buf[i+4:]...)
+			out = append(out, c.rewriteHeader(c.buf[:i+4])...)
+			c.buf = nil
+		case h1StatePassthrough:
+			out = append(out, rest...)
+			rest = nil
+		case h1StateBody, h1StateChunkData:
+			n := int64(len(rest))
+			if n > c.remain {
+				n = c.remain
+			}
+			out = append(out, rest[:n]...)
+			rest = rest[n:]
+			c.remain -= n
+			if c.remain == 0 {
+				if c.state == h1StateBody {
+					c.state = h1StateHeader
+				} else {
+					c.state = h1StateChunkSize
+				}
+			}
+		case h1StateChunkSize, h1StateTrailer:
+			i := bytes.IndexByte(rest, '\n')
+			if i < 0 {
+				c.buf = append(c.buf, rest...)
+				out = append(out, rest...)
+				rest = nil
+				continue
+			}
+			line := append(c.buf, rest[:i+1]...)
+			out = append(out, rest[:i+1]...)
+			rest = rest[i+1:]
+			c.buf = nil
+			c.endLine(strings.TrimSpace(string(line)))
+		}
+	}
+
+	if len(out) > 0 {
+		if _, err := c.Conn.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// endLine 处理 chunk 大小行或 trailer 行
+func (c *h1HeaderOrderConn) endLine(line string) {
+	if c.state == h1StateTrailer {
+		if line == "" {
+			c.state = h1StateHeader
+		}
+		return
+	}
+
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		line = line[:i]
+	}
+	size, _ := strconv.ParseInt(strings.TrimSpace(line), 16, 64)
+	if size == 0 {
+		c.state = h1StateTrailer
+		return
+	}
+	// chunk 数据后面还有 \r\n
+	c.remain = size + 2
+	c.state = h1StateChunkData
+}
+
+// rewriteHeader 按顺序标记重排请求头 并根据 Content-Length 和 Transfer-Encoding 确定 body 长度
+// 没有顺序标记时返回原来的 head Upgrade 请求等待响应的状态行
+func (c *h1HeaderOrderConn) rewriteHeader(head []byte) []byte {
+	lines := strings.Split(strings.TrimSuffix(string(head), "\r\n\r\n"), "\r\n")
+	requestLine, fields := lines[0], lines[1:]
+
+	var (
+		order   []string
+		marked  bool
+		upgrade bool
+	)
+	kept := fields[:0]
+	c.state = h1StateHeader
+	for _, line := range fields {
+		name, value, _ := strings.Cut(line, ":")
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.EqualFold(name, headerOrderKey):
+			order = splitHeaderOrder(value)
+			marked = true
+			continue
+		case strings.EqualFold(name, pseudoHeaderOrderKey):
+			marked = true
+			continue
+		case strings.EqualFold(name, "Content-Length"):
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil && n > 0 {
+				c.remain = n
+				c.state = h1StateBody
+			}
+		case strings.EqualFold(name, "Transfer-Encoding"):
+			if strings.Contains(strings.ToLower(value), "chunked") {
+				c.state = h1StateChunkSize
+			}
+		case strings.EqualFold(name, "Upgrade"):
+			upgrade = true
+		case strings.EqualFold(name, "Connection"):
+			if strings.Contains(strings.ToLower(value), "upgrade") {
+				upgrade = true
+			}
+		}
+		kept = append(kept, line)
+	}
+	if upgrade {
+		c.upgrading.Store(true)
+	}
+	if !marked {
+		return head
+	}
+
+	sortByOrder(kept, order, func(line string) string {
+		name, _, _ := strings.Cut(line, ":")
+		return name
+	})
+
+	var b strings.Builder
+	b.WriteString(requestLine)
+	b.WriteString("\r\n")
+	for _, line := range kept {
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
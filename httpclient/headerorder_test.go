@@ -0,0 +1,282 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// serveRawHTTP1 记录每个请求的头部名字和 body
+func serveRawHTTP1(t *testing.T, ln net.Listener, heads chan<- []string, bodies chan<- string) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	br := bufio.NewReader(conn)
+	for {
+		var (
+			names   []string
+			length  int
+			chunked bool
+		)
+		if _, err := br.ReadString('\n'); err != nil {
+			return
+		}
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			if line == "" {
+				break
+			}
+			name, value, _ := strings.Cut(line, ":")
+			names = append(names, name)
+			switch strings.ToLower(name) {
+			case "content-length":
+				length, _ = strconv.Atoi(strings.TrimSpace(value))
+			case "transfer-encoding":
+				chunked = true
+			}
+		}
+
+		var body []byte
+		if chunked {
+			body, err = io.ReadAll(httputil.NewChunkedReader(br))
+			_, _ = br.ReadString('\n') // chunked 结束的空行
+		} else {
+			body = make([]byte, length)
+			_, err = io.ReadFull(br, body)
+		}
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		heads <- names
+		bodies <- string(body)
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok"))
+	}
+}
+
+func TestHeaderOrder_HTTP1(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	heads := make(chan []string, 3)
+	bodies := make(chan string, 3)
+	go serveRawHTTP1(t, ln, heads, bodies)
+
+	order := []string{"Content-Type", "X-Second", "User-Agent", "X-First", "Host"}
+	client := NewClient(WithTransport(NewTransport()))
+	for _, tc := range []struct {
+		body io.Reader
+		want string
+	}{
+		{strings.NewReader("fixed length body"), "fixed length body"},
+		{io.MultiReader(strings.NewReader("chunked "), strings.NewReader("body")), "chunked body"},
+		{nil, ""},
+	} {
+		req := client.NewRequest(http.MethodPost, "http://"+ln.Addr().String()+"/").
+			SetOrderedHeads([][2]string{{"X-Second", "2"}, {"X-First", "1"}}).
+			SetHead("User-Agent", "test").
+			SetHeaderOrder(order)
+		if tc.body != nil {
+			req.SetBody("text/plain", tc.body)
+		}
+		code, resp, err := req.String()
+		if err != nil || code != http.StatusOK || resp != "ok" {
+			t.Fatalf("code = %d, resp = %q, err = %v", code, resp, err)
+		}
+
+		names := <-heads
+		if body := <-bodies; body != tc.want {
+			t.Fatalf("body = %q, want %q", body, tc.want)
+		}
+
+		var ordered []string
+		for _, name := range names {
+			for _, o := range order {
+				if strings.EqualFold(name, o) {
+					ordered = append(ordered, o)
+				}
+			}
+			if strings.EqualFold(name, headerOrderKey) {
+				t.Fatal("header order key sent to server")
+			}
+		}
+		if tc.body == nil {
+			order = order[1:]
+		}
+		if strings.Join(ordered, ",") != strings.Join(order, ",") {
+			t.Fatalf("header order = %v, want %v", names, order)
+		}
+	}
+}
+
+func TestHeaderOrder_Upgrade(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		_, _ = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		_ = brw.Flush()
+		// 升级后按行回显
+		line, _ := brw.ReadString('\n')
+		_, _ = conn.Write([]byte("echo " + line))
+	}))
+	defer s.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	ctx, cancel := context.WithTimeout(NewHeaderOrderContext(context.Background(), []string{"Upgrade", "Connection"}, nil), 5*time.Second)
+	defer cancel()
+	resp, err := NewTransport().RoundTrip(req.WithContext(ctx))
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("resp = %v, err = %v", resp, err)
+	}
+	rw := resp.Body.(io.ReadWriteCloser)
+	defer rw.Close()
+	stop := context.AfterFunc(ctx, func() { rw.Close() })
+	defer stop()
+
+	if _, err = rw.Write([]byte("ping\n")); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(rw).ReadString('\n')
+	if err != nil || line != "echo ping\n" {
+		t.Fatalf("line = %q, err = %v", line, err)
+	}
+}
+
+func TestHeaderOrder_UpgradeRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// 只接受一个连接 拒绝升级后第二个 Upgrade 请求复用同一个连接
+	heads := make(chan []string, 2)
+	bodies := make(chan string, 2)
+	go serveRawHTTP1(t, ln, heads, bodies)
+
+	order := []string{"X-Second", "User-Agent", "X-First"}
+	client := NewClient(WithTransport(NewTransport()))
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		req := client.NewRequest(http.MethodGet, "http://"+ln.Addr().String()+"/").
+			WithContext(ctx).
+			SetOrderedHeads([][2]string{{"X-Second", "2"}, {"X-First", "1"}}).
+			SetHead("User-Agent", "test").
+			SetHead("Connection", "Upgrade").
+			SetHead("Upgrade", "websocket").
+			SetHeaderOrder(order)
+		code, resp, err := req.String()
+		cancel()
+		if err != nil || code != http.StatusOK || resp != "ok" {
+			t.Fatalf("request %d: code = %d, resp = %q, err = %v", i, code, resp, err)
+		}
+		<-bodies
+
+		var ordered []string
+		for _, name := range <-heads {
+			if strings.EqualFold(name, headerOrderKey) || strings.EqualFold(name, pseudoHeaderOrderKey) {
+				t.Fatalf("request %d: header order key sent to server", i)
+			}
+			for _, o := range order {
+				if strings.EqualFold(name, o) {
+					ordered = append(ordered, o)
+				}
+			}
+		}
+		if strings.Join(ordered, ",") != strings.Join(order, ",") {
+			t.Fatalf("request %d: header order = %v, want %v", i, ordered, order)
+		}
+	}
+}
+
+func TestHeaderOrder_HTTP2(t *testing.T) {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("X-First")))
+	}))
+	s.EnableHTTP2 = true
+	s.StartTLS()
+	defer s.Close()
+
+	var record *recordConn
+	tr := &http2.Transport{}
+	tr.DialTLSContext = func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+		conn, err := tls.Dial(network, addr, &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2"}})
+		if err != nil {
+			return nil, err
+		}
+		record = &recordConn{Conn: conn}
+		return newH2FingerprintConn(record, nil), nil
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+	req.Header.Set("X-Second", "2")
+	req.Header.Set("X-First", "1")
+	req.Header.Set(headerOrderKey, "x-second,x-first")
+	req.Header.Set(pseudoHeaderOrderKey, ":path,:method,:scheme,:authority")
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(data) != "1" {
+		t.Fatalf("resp = %q", data)
+	}
+
+	record.mu.Lock()
+	written := record.buf.Bytes()
+	record.mu.Unlock()
+
+	framer := http2.NewFramer(nil, bytes.NewReader(written[len(http2.ClientPreface):]))
+	var names []string
+	for {
+		f, err := framer.ReadFrame()
+		if err != nil {
+			break
+		}
+		if f, ok := f.(*http2.HeadersFrame); ok {
+			fields, err := hpack.NewDecoder(4096, nil).DecodeFull(f.HeaderBlockFragment())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, field := range fields {
+				names = append(names, field.Name)
+			}
+		}
+	}
+
+	want := ":path,:method,:scheme,:authority,x-second,x-first"
+	if got := strings.Join(names, ","); !strings.HasPrefix(got, want) || strings.Contains(got, "x-httpclient") {
+		t.Fatalf("headers = %s, want prefix %s", got, want)
+	}
+}
@@ -3,6 +3,7 @@ package httpclient
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -32,21 +33,11 @@ func (transpoort *Transport) JA3() (string, string) {
 		ja3Extentions    *Ja3Slice
 		ja3SupportGroups *Ja3Slice
 		ja3Points        *Ja3Slice
-		clientHelloSpec  *tls.ClientHelloSpec
 	)
-	if transpoort.ClientHelloID == tls.HelloCustom {
-		if transpoort.ClientHelloSpec == nil {
-			fmt.Println("Custome client hello spec is nil")
-			return "", ""
-		}
-		clientHelloSpec = transpoort.ClientHelloSpec
-	} else {
-		clientHelloStruct, err := tls.UTLSIdToSpec(transpoort.ClientHelloID)
-		if err != nil {
-			fmt.Println("UTLSIdToSpec error: ", err)
-			return "", ""
-		}
-		clientHelloSpec = &clientHelloStruct
+	clientHelloSpec, err := transpoort.clientHelloSpec()
+	if err != nil {
+		fmt.Println(err)
+		return "", ""
 	}
 	ja3Cipher = transpoort.getCipherSuite(clientHelloSpec)
 	ja3Extentions = transpoort.getExtensions(clientHelloSpec)
@@ -61,6 +52,22 @@ func (transpoort *Transport) JA3() (string, string) {
 	return ja3, ja3Md5
 }
 
+// clientHelloSpec 返回计算指纹使用的 ClientHelloSpec
+func (transpoort *Transport) clientHelloSpec() (*tls.ClientHelloSpec, error) {
+	if transpoort.ClientHelloID == tls.HelloCustom {
+		if transpoort.ClientHelloSpec == nil {
+			return nil, errors.New("Custome client hello spec is nil")
+		}
+		return transpoort.ClientHelloSpec, nil
+	}
+
+	clientHelloStruct, err := tls.UTLSIdToSpec(transpoort.ClientHelloID)
+	if err != nil {
+		return nil, fmt.Errorf("UTLSIdToSpec error: %w", err)
+	}
+	return &clientHelloStruct, nil
+}
+
 func (transpoort *Transport) getCipherSuite(clientHello *tls.ClientHelloSpec) *Ja3Slice {
 	res := make(Ja3Slice, 0, len(clientHello.CipherSuites))
 	for _, i := range clientHello.CipherSuites {
@@ -0,0 +1,238 @@
+package httpclient
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// JA3Hints JA3 只记录扩展的编号 扩展的内容通过 JA3Hints 指定 零值使用 Chrome 的默认值
+type JA3Hints struct {
+	ALPN                 []string                  // 16 默认 h2 http/1.1
+	SignatureAlgorithms  []tls.SignatureScheme     // 13 和 50
+	SupportedVersions    []uint16                  // 43 默认 TLS1.3 TLS1.2
+	KeyShareCurves       []tls.CurveID             // 51 默认使用支持的第一个曲线
+	CertCompressionAlgos []tls.CertCompressionAlgo // 27 默认 brotli
+	ALPS                 []string                  // 17513 默认 h2
+	PSKModes             []uint8                   // 45 默认 psk_dhe_ke
+	RecordSizeLimit      uint16                    // 28 默认 0x4001
+	DelegatedCredentials []tls.SignatureScheme     // 34
+
+	// GREASE 按 Chrome 的方式插入 GREASE JA3 计算时会去掉 GREASE
+	GREASE bool
+
+	// Payloads 其他扩展的原始内容 不包括扩展编号和长度 未指定的扩展内容为空
+	Payloads map[uint16][]byte
+}
+
+var defaultJA3SignatureAlgorithms = []tls.SignatureScheme{
+	tls.ECDSAWithP256AndSHA256,
+	tls.PSSWithSHA256,
+	tls.PKCS1WithSHA256,
+	tls.ECDSAWithP384AndSHA384,
+	tls.PSSWithSHA384,
+	tls.PKCS1WithSHA384,
+	tls.PSSWithSHA512,
+	tls.PKCS1WithSHA512,
+}
+
+var defaultJA3DelegatedCredentials = []tls.SignatureScheme{
+	tls.ECDSAWithP256AndSHA256,
+	tls.ECDSAWithP384AndSHA384,
+	tls.ECDSAWithP521AndSHA512,
+	tls.ECDSAWithSHA1,
+}
+
+// ParseJA3 把JA3字符串转换为 ClientHelloSpec 配合 HelloCustom 使用
+// ja3 格式为 SSLVersion,Ciphers,Extensions,EllipticCurves,EllipticCurvePointFormats
+// pre_shared_key(41) 和 early_data(42) 依赖会话恢复 无法伪造 会被忽略
+func ParseJA3(ja3 string, hints *JA3Hints) (*tls.ClientHelloSpec, error) {
+	if hints == nil {
+		hints = &JA3Hints{}
+	}
+
+	fields := strings.Split(strings.TrimSpace(ja3), ",")
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("httpclient: invalid ja3 %q: want 5 fields, got %d", ja3, len(fields))
+	}
+
+	version, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("httpclient: invalid ja3 version %q", fields[0])
+	}
+
+	ciphers, err := parseJA3List(fields[1], 16)
+	if err != nil {
+		return nil, fmt.Errorf("httpclient: invalid ja3 ciphers: %w", err)
+	}
+	extensions, err := parseJA3List(fields[2], 16)
+	if err != nil {
+		return nil, fmt.Errorf("httpclient: invalid ja3 extensions: %w", err)
+	}
+	curveIDs, err := parseJA3List(fields[3], 16)
+	if err != nil {
+		return nil, fmt.Errorf("httpclient: invalid ja3 curves: %w", err)
+	}
+	pointIDs, err := parseJA3List(fields[4], 8)
+	if err != nil {
+		return nil, fmt.Errorf("httpclient: invalid ja3 point formats: %w", err)
+	}
+
+	curves := make([]tls.CurveID, 0, len(curveIDs)+1)
+	if hints.GREASE {
+		curves = append(curves, tls.CurveID(tls.GREASE_PLACEHOLDER))
+	}
+	for _, id := range curveIDs {
+		curves = append(curves, tls.CurveID(id))
+	}
+
+	points := make([]uint8, 0, len(pointIDs))
+	for _, id := range pointIDs {
+		points = append(points, uint8(id))
+	}
+
+	spec := &tls.ClientHelloSpec{
+		TLSVersMin:         tls.VersionTLS10,
+		TLSVersMax:         uint16(version),
+		CompressionMethods: []uint8{0x00},
+	}
+
+	if hints.GREASE {
+		spec.CipherSuites = append(spec.CipherSuites, tls.GREASE_PLACEHOLDER)
+		spec.Extensions = append(spec.Extensions, &tls.UtlsGREASEExtension{})
+	}
+	spec.CipherSuites = append(spec.CipherSuites, ciphers...)
+
+	for _, id := range extensions {
+		if payload, ok := hints.Payloads[id]; ok {
+			spec.Extensions = append(spec.Extensions, &tls.GenericExtension{Id: id, Data: payload})
+			continue
+		}
+
+		var ext tls.TLSExtension
+		switch id {
+		case 0:
+			ext = &tls.SNIExtension{}
+		case 5:
+			ext = &tls.StatusRequestExtension{}
+		case 10:
+			ext = &tls.SupportedCurvesExtension{Curves: curves}
+		case 11:
+			ext = &tls.SupportedPointsExtension{SupportedPoints: points}
+		case 13:
+			ext = &tls.SignatureAlgorithmsExtension{SupportedSignatureAlgorithms: orDefault(hints.SignatureAlgorithms, defaultJA3SignatureAlgorithms)}
+		case 16:
+			ext = &tls.ALPNExtension{AlpnProtocols: orDefault(hints.ALPN, []string{"h2", "http/1.1"})}
+		case 17:
+			ext = &tls.StatusRequestV2Extension{}
+		case 18:
+			ext = &tls.SCTExtension{}
+		case 21:
+			ext = &tls.UtlsPaddingExtension{GetPaddingLen: tls.BoringPaddingStyle}
+		case 23:
+			ext = &tls.ExtendedMasterSecretExtension{}
+		case 27:
+			ext = &tls.UtlsCompressCertExtension{Algorithms: orDefault(hints.CertCompressionAlgos, []tls.CertCompressionAlgo{tls.CertCompressionBrotli})}
+		case 28:
+			limit := hints.RecordSizeLimit
+			if limit == 0 {
+				limit = 0x4001
+			}
+			ext = &tls.FakeRecordSizeLimitExtension{Limit: limit}
+		case 34:
+			ext = &tls.FakeDelegatedCredentialsExtension{SupportedSignatureAlgorithms: orDefault(hints.DelegatedCredentials, defaultJA3DelegatedCredentials)}
+		case 35:
+			ext = &tls.SessionTicketExtension{}
+		case 41, 42:
+			continue
+		case 43:
+			versions := orDefault(hints.SupportedVersions, []uint16{tls.VersionTLS13, tls.VersionTLS12})
+			if hints.GREASE {
+				versions = append([]uint16{tls.GREASE_PLACEHOLDER}, versions...)
+			}
+			ext = &tls.SupportedVersionsExtension{Versions: versions}
+			spec.TLSVersMax = tls.VersionTLS13
+		case 45:
+			ext = &tls.PSKKeyExchangeModesExtension{Modes: orDefault(hints.PSKModes, []uint8{tls.PskModeDHE})}
+		case 50:
+			ext = &tls.SignatureAlgorithmsCertExtension{SupportedSignatureAlgorithms: orDefault(hints.SignatureAlgorithms, defaultJA3SignatureAlgorithms)}
+		case 51:
+			ext = &tls.KeyShareExtension{KeyShares: ja3KeyShares(hints, curveIDs)}
+		case 13172:
+			ext = &tls.NPNExtension{}
+		case 17513:
+			ext = &tls.ApplicationSettingsExtension{SupportedProtocols: orDefault(hints.ALPS, []string{"h2"})}
+		case 30031:
+			ext = &tls.FakeChannelIDExtension{OldExtensionID: true}
+		case 30032:
+			ext = &tls.FakeChannelIDExtension{}
+		case 65281:
+			ext = &tls.RenegotiationInfoExtension{Renegotiation: tls.RenegotiateOnceAsClient}
+		default:
+			ext = &tls.GenericExtension{Id: id}
+		}
+		spec.Extensions = append(spec.Extensions, ext)
+	}
+
+	if hints.GREASE {
+		// Chrome 的第二个 GREASE 扩展在 padding 之前
+		n := len(spec.Extensions)
+		if _, ok := spec.Extensions[n-1].(*tls.UtlsPaddingExtension); ok {
+			spec.Extensions = append(spec.Extensions[:n-1], &tls.UtlsGREASEExtension{}, spec.Extensions[n-1])
+		} else {
+			spec.Extensions = append(spec.Extensions, &tls.UtlsGREASEExtension{})
+		}
+	}
+
+	return spec, nil
+}
+
+func parseJA3List(field string, bitSize int) ([]uint16, error) {
+	if field == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(field, "-")
+	values := make([]uint16, 0, len(parts))
+	for _, part := range parts {
+		v, err := strconv.ParseUint(part, 10, bitSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q", part)
+		}
+		if isGREASEUint16(uint16(v)) {
+			continue
+		}
+		values = append(values, uint16(v))
+	}
+
+	return values, nil
+}
+
+func ja3KeyShares(hints *JA3Hints, curveIDs []uint16) []tls.KeyShare {
+	var shares []tls.KeyShare
+	if hints.GREASE {
+		shares = append(shares, tls.KeyShare{Group: tls.CurveID(tls.GREASE_PLACEHOLDER), Data: []byte{0}})
+	}
+
+	groups := hints.KeyShareCurves
+	if len(groups) == 0 {
+		groups = []tls.CurveID{tls.X25519}
+		if len(curveIDs) > 0 {
+			groups = []tls.CurveID{tls.CurveID(curveIDs[0])}
+		}
+	}
+	for _, group := range groups {
+		shares = append(shares, tls.KeyShare{Group: group})
+	}
+
+	return shares
+}
+
+func orDefault[T any](v, def []T) []T {
+	if len(v) == 0 {
+		return def
+	}
+	return v
+}
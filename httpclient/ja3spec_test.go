@@ -0,0 +1,75 @@
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+const chromeJA3 = "771,4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-49172-156-157-47-53," +
+	"0-23-65281-10-11-35-16-5-13-18-51-45-43-27-17513-21,29-23-24,0"
+
+func TestParseJA3(t *testing.T) {
+	tests := []struct {
+		name  string
+		ja3   string
+		hints *JA3Hints
+	}{
+		{"chrome", chromeJA3, nil},
+		{"chrome grease", chromeJA3, &JA3Hints{GREASE: true, ALPN: []string{"http/1.1"}}},
+		{"firefox", "771,4865-4867-4866-49195-49199-52393-52392-49196-49200-49162-49161-49171-49172-156-157-47-53," +
+			"0-23-65281-10-11-35-16-5-34-51-43-13-45-28-21,29-23-24-25-256-257,0", nil},
+		{"tls12", "771,49199-49195-49200,0-10-11-13-23-65281,23-24,0", nil},
+	}
+
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := ParseJA3(tt.ja3, tt.hints)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tr := NewTransport(WithTlsConnOptClientHelloID(tls.HelloCustom), WithTlsConnOptClientHelloSpec(spec))
+			if ja3, _ := tr.JA3(); ja3 != tt.ja3 {
+				t.Fatalf("JA3() = %s\nwant %s", ja3, tt.ja3)
+			}
+
+			// 能和服务端正常握手
+			conn, err := net.Dial("tcp", s.Listener.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			copySpec := new(tls.ClientHelloSpec)
+			_ = tr.deepCopyClientHelloSpec(copySpec, spec)
+			uconn := tls.UClient(conn, &tls.Config{InsecureSkipVerify: true}, tls.HelloCustom)
+			if err = uconn.ApplyPreset(copySpec); err != nil {
+				t.Fatal(err)
+			}
+			if err = uconn.Handshake(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestParseJA3_Invalid(t *testing.T) {
+	for _, ja3 := range []string{"", "771,1-2,0", "x,1,0,29,0", "771,1,0,29,256"} {
+		if _, err := ParseJA3(ja3, nil); err == nil {
+			t.Errorf("ParseJA3(%q) want error", ja3)
+		}
+	}
+
+	tr := NewTransport(WithTlsConnOptJA3("bad"))
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if _, err := tr.RoundTrip(req); err == nil {
+		t.Fatal("RoundTrip() want ja3 error")
+	}
+}
@@ -0,0 +1,126 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// JA4 获取ja4指纹 和 JA3() 使用同一个 ClientHelloSpec
+// 格式为 t13d1516h2_8daaf6152771_e5627efa2ab1
+func (t *Transport) JA4() (string, error) {
+	clientHelloSpec, err := t.clientHelloSpec()
+	if err != nil {
+		return "", err
+	}
+
+	return ja4(clientHelloSpec, t.getCipherSuite(clientHelloSpec), t.getExtensions(clientHelloSpec)), nil
+}
+
+func ja4(spec *tls.ClientHelloSpec, ciphers, extensions *Ja3Slice) string {
+	var (
+		version    = spec.TLSVersMax
+		sni        = "i"
+		alpn       = "00"
+		signatures []string
+	)
+
+	for _, extension := range spec.Extensions {
+		switch ext := extension.(type) {
+		case *tls.SNIExtension:
+			sni = "d"
+		case *tls.SupportedVersionsExtension:
+			version = 0
+			for _, v := range ext.Versions {
+				if !isGREASEUint16(v) && v > version {
+					version = v
+				}
+			}
+		case *tls.ALPNExtension:
+			if len(ext.AlpnProtocols) > 0 {
+				alpn = ja4ALPN(ext.AlpnProtocols[0])
+			}
+		case *tls.SignatureAlgorithmsExtension:
+			for _, sig := range ext.SupportedSignatureAlgorithms {
+				signatures = append(signatures, fmt.Sprintf("%04x", uint16(sig)))
+			}
+		}
+	}
+
+	// 排序时不包括 SNI 和 ALPN 数量包括
+	var sortedExtensions []string
+	for _, ext := range *extensions {
+		if ext == 0x0000 || ext == 0x0010 {
+			continue
+		}
+		sortedExtensions = append(sortedExtensions, fmt.Sprintf("%04x", ext))
+	}
+	sort.Strings(sortedExtensions)
+
+	sortedCiphers := make([]string, 0, len(*ciphers))
+	for _, cipher := range *ciphers {
+		sortedCiphers = append(sortedCiphers, fmt.Sprintf("%04x", cipher))
+	}
+	sort.Strings(sortedCiphers)
+
+	extensionHash := "000000000000"
+	if len(sortedExtensions) > 0 {
+		raw := strings.Join(sortedExtensions, ",")
+		if len(signatures) > 0 {
+			raw += "_" + strings.Join(signatures, ",")
+		}
+		extensionHash = ja4Hash(raw)
+	}
+
+	cipherHash := "000000000000"
+	if len(sortedCiphers) > 0 {
+		cipherHash = ja4Hash(strings.Join(sortedCiphers, ","))
+	}
+
+	return fmt.Sprintf("t%s%s%02d%02d%s_%s_%s", ja4Version(version), sni,
+		min(len(*ciphers), 99), min(len(*extensions), 99), alpn, cipherHash, extensionHash)
+}
+
+func ja4Version(version uint16) string {
+	switch version {
+	case tls.VersionTLS13:
+		return "13"
+	case tls.VersionTLS12:
+		return "12"
+	case tls.VersionTLS11:
+		return "11"
+	case tls.VersionTLS10:
+		return "10"
+	case tls.VersionSSL30:
+		return "s3"
+	default:
+		return "00"
+	}
+}
+
+// ja4ALPN 取第一个ALPN的首尾字符 不是字母数字时取首字节十六进制的第一位和尾字节的最后一位
+func ja4ALPN(proto string) string {
+	if proto == "" {
+		return "00"
+	}
+
+	first, last := proto[0], proto[len(proto)-1]
+	if isAlnum(first) && isAlnum(last) {
+		return string([]byte{first, last})
+	}
+
+	return hex.EncodeToString([]byte{first})[:1] + hex.EncodeToString([]byte{last})[1:]
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func ja4Hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:12]
+}
@@ -0,0 +1,267 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMetricsBuckets 耗时直方图默认的桶 单位秒
+var DefaultMetricsBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// MetricsCollector 按host统计请求耗时、错误数和连接复用 实现 EventHook
+// 可以通过 WithEventHook 或 WithTlsConnOptEventHook 设置 通过 WritePrometheus 导出
+type MetricsCollector struct {
+	buckets []float64
+
+	mu    sync.Mutex
+	hosts map[string]*hostMetrics
+}
+
+type hostMetrics struct {
+	requests    int64
+	errors      int64
+	newConns    int64
+	reusedConns int64
+
+	dns       *histogram
+	dial      *histogram
+	handshake *histogram
+	firstByte *histogram
+	duration  *histogram
+}
+
+// HostStats 一个host的统计
+type HostStats struct {
+	Requests    int64 // 完成的请求数
+	Errors      int64 // 失败的请求数 不包括非2xx的响应
+	NewConns    int64 // 新建的连接数
+	ReusedConns int64 // 复用的连接数
+
+	DNS          HistogramStats
+	Dial         HistogramStats
+	TLSHandshake HistogramStats
+	FirstByte    HistogramStats
+	Duration     HistogramStats
+}
+
+// HistogramStats 耗时直方图 Counts 与桶一一对应 不累加 最后一个为超过最大桶的数量
+type HistogramStats struct {
+	Buckets []float64
+	Counts  []int64
+	Count   int64
+	Sum     time.Duration
+}
+
+// Mean 平均耗时
+func (h HistogramStats) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+type histogram struct {
+	buckets []float64
+	counts  []int64
+	count   int64
+	sum     time.Duration
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]int64, len(buckets)+1)}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := sort.SearchFloat64s(h.buckets, d.Seconds())
+	h.counts[i]++
+	h.count++
+	h.sum += d
+}
+
+func (h *histogram) stats() HistogramStats {
+	return HistogramStats{
+		Buckets: h.buckets,
+		Counts:  append([]int64(nil), h.counts...),
+		Count:   h.count,
+		Sum:     h.sum,
+	}
+}
+
+// NewMetricsCollector buckets 为耗时直方图的桶 单位秒 为空时使用 DefaultMetricsBuckets
+func NewMetricsCollector(buckets ...float64) *MetricsCollector {
+	if len(buckets) == 0 {
+		buckets = DefaultMetricsBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &MetricsCollector{
+		buckets: buckets,
+		hosts:   make(map[string]*hostMetrics),
+	}
+}
+
+func (m *MetricsCollector) OnEvent(ctx context.Context, event Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hm := m.hosts[event.Host]
+	if hm == nil {
+		hm = &hostMetrics{
+			dns:       newHistogram(m.buckets),
+			dial:      newHistogram(m.buckets),
+			handshake: newHistogram(m.buckets),
+			firstByte: newHistogram(m.buckets),
+			duration:  newHistogram(m.buckets),
+		}
+		m.hosts[event.Host] = hm
+	}
+
+	switch event.Type {
+	case EventDNSDone:
+		if event.Err == nil {
+			hm.dns.observe(event.Duration)
+		}
+	case EventDialDone:
+		if event.Err == nil {
+			hm.dial.observe(event.Duration)
+		}
+	case EventTLSHandshakeDone:
+		if event.Err == nil {
+			hm.handshake.observe(event.Duration)
+		}
+	case EventGotConn:
+		if event.Reused {
+			hm.reusedConns++
+		} else {
+			hm.newConns++
+		}
+	case EventFirstByte:
+		hm.firstByte.observe(event.Duration)
+	case EventDone:
+		hm.requests++
+		if event.Err != nil {
+			hm.errors++
+		}
+		hm.duration.observe(event.Duration)
+	}
+}
+
+// Hosts 有统计的host 已排序
+func (m *MetricsCollector) Hosts() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hosts := make([]string, 0, len(m.hosts))
+	for host := range m.hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// Stats 返回host的统计 没有请求过时返回零值
+func (m *MetricsCollector) Stats(host string) HostStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hm := m.hosts[host]
+	if hm == nil {
+		return HostStats{}
+	}
+	return HostStats{
+		Requests:     hm.requests,
+		Errors:       hm.errors,
+		NewConns:     hm.newConns,
+		ReusedConns:  hm.reusedConns,
+		DNS:          hm.dns.stats(),
+		Dial:         hm.dial.stats(),
+		TLSHandshake: hm.handshake.stats(),
+		FirstByte:    hm.firstByte.stats(),
+		Duration:     hm.duration.stats(),
+	}
+}
+
+// Reset 清空所有统计
+func (m *MetricsCollector) Reset() {
+	m.mu.Lock()
+	m.hosts = make(map[string]*hostMetrics)
+	m.mu.Unlock()
+}
+
+// WritePrometheus 以 Prometheus 文本格式输出 指标名以 httpclient_ 开头 host 为标签
+func (m *MetricsCollector) WritePrometheus(w io.Writer) error {
+	hosts := m.Hosts()
+	stats := make([]HostStats, len(hosts))
+	for i, host := range hosts {
+		stats[i] = m.Stats(host)
+	}
+
+	bw := bufio.NewWriter(w)
+	counters := []struct {
+		name, help string
+		value      func(HostStats) int64
+	}{
+		{"httpclient_requests_total", "Total number of completed requests.", func(s HostStats) int64 { return s.Requests }},
+		{"httpclient_request_errors_total", "Total number of requests failed with an error.", func(s HostStats) int64 { return s.Errors }},
+	}
+	for _, c := range counters {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for i, host := range hosts {
+			fmt.Fprintf(bw, "%s{host=%s} %d\n", c.name, quoteLabel(host), c.value(stats[i]))
+		}
+	}
+
+	const conns = "httpclient_connections_total"
+	fmt.Fprintf(bw, "# HELP %s Total number of connections obtained, by whether they were reused.\n# TYPE %s counter\n", conns, conns)
+	for i, host := range hosts {
+		fmt.Fprintf(bw, "%s{host=%s,reused=\"false\"} %d\n", conns, quoteLabel(host), stats[i].NewConns)
+		fmt.Fprintf(bw, "%s{host=%s,reused=\"true\"} %d\n", conns, quoteLabel(host), stats[i].ReusedConns)
+	}
+
+	histograms := []struct {
+		name, help string
+		value      func(HostStats) HistogramStats
+	}{
+		{"httpclient_dns_duration_seconds", "DNS lookup latency.", func(s HostStats) HistogramStats { return s.DNS }},
+		{"httpclient_dial_duration_seconds", "TCP dial latency.", func(s HostStats) HistogramStats { return s.Dial }},
+		{"httpclient_tls_handshake_duration_seconds", "TLS handshake latency.", func(s HostStats) HistogramStats { return s.TLSHandshake }},
+		{"httpclient_first_byte_duration_seconds", "Latency until the first response byte.", func(s HostStats) HistogramStats { return s.FirstByte }},
+		{"httpclient_request_duration_seconds", "Latency until the response body is consumed.", func(s HostStats) HistogramStats { return s.Duration }},
+	}
+	for _, h := range histograms {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+		for i, host := range hosts {
+			hs := h.value(stats[i])
+			label := quoteLabel(host)
+			var cumulative int64
+			for j, bucket := range hs.Buckets {
+				cumulative += hs.Counts[j]
+				fmt.Fprintf(bw, "%s_bucket{host=%s,le=\"%s\"} %d\n", h.name, label, strconv.FormatFloat(bucket, 'g', -1, 64), cumulative)
+			}
+			fmt.Fprintf(bw, "%s_bucket{host=%s,le=\"+Inf\"} %d\n", h.name, label, hs.Count)
+			fmt.Fprintf(bw, "%s_sum{host=%s} %s\n", h.name, label, strconv.FormatFloat(hs.Sum.Seconds(), 'g', -1, 64))
+			fmt.Fprintf(bw, "%s_count{host=%s} %d\n", h.name, label, hs.Count)
+		}
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP 用于暴露 /metrics
+func (m *MetricsCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = m.WritePrometheus(w)
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quoteLabel(value string) string {
+	return `"` + labelReplacer.Replace(value) + `"`
+}
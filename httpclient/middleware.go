@@ -0,0 +1,102 @@
+package httpclient
+
+import (
+	"net/http"
+	"time"
+)
+
+// Handler 发送一次请求并返回结果
+type Handler func(req *http.Request) (*Response, error)
+
+// Middleware 请求中间件 包装一次请求的发送过程
+// 可以修改发送前的 *http.Request 和返回的 *Response
+type Middleware func(next Handler) Handler
+
+// chainMiddleware 按添加顺序组装中间件 第一个中间件在最外层
+func chainMiddleware(h Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+func WithMiddleware(mws ...Middleware) ClientOption {
+	return func(client *Client) {
+		client.middlewares = append(client.middlewares, mws...)
+	}
+}
+
+// Use 添加client中间件 对该client的所有请求生效
+func (c *Client) Use(mws ...Middleware) *Client {
+	c.middlewares = append(c.middlewares, mws...)
+	return c
+}
+
+// Use 添加该请求的中间件 在client中间件之后执行
+func (r *Request) Use(mws ...Middleware) *Request {
+	r.middlewares = append(r.middlewares, mws...)
+	return r
+}
+
+// HeaderMiddleware 发送请求前设置head
+func HeaderMiddleware(header http.Header) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*Response, error) {
+			for key, values := range header {
+				req.Header[key] = values
+			}
+			return next(req)
+		}
+	}
+}
+
+// CheckProxyMiddleware 代理检查 checkProxy 返回 false 时通知 selector 代理失效
+// selector 实现了 ProxyReporter 时每次请求结束都上报结果
+func CheckProxyMiddleware(checkProxy func(response *Response) bool, selector ProxySelector) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*Response, error) {
+			start := time.Now()
+			resp, err := next(req)
+
+			invalid := checkProxy != nil && selector != nil && !checkProxy(resp)
+			if invalid {
+				selector.ProxyInvalid(req.Context())
+			}
+
+			if reporter, ok := selector.(ProxyReporter); ok {
+				reportErr := err
+				if reportErr == nil && invalid {
+					reportErr = errProxyCheckFailed
+				}
+				reporter.ReportProxy(req.Context(), time.Since(start), reportErr)
+			}
+
+			return resp, err
+		}
+	}
+}
+
+// DecryptMiddleware 解密返回结果的body 解密后通过 Response.Body 获取
+func DecryptMiddleware(decrypt DecryptFunc) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*Response, error) {
+			resp, err := next(req)
+			if err != nil || decrypt == nil {
+				return resp, err
+			}
+
+			body, err := resp.Body()
+			if err != nil {
+				return resp, err
+			}
+
+			plain, err := decrypt(string(body))
+			if err != nil {
+				return resp, err
+			}
+
+			resp.body = []byte(plain)
+			return resp, nil
+		}
+	}
+}
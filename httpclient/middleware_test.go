@@ -0,0 +1,114 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware_Order(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("X-Sign")))
+	}))
+	defer s.Close()
+
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(req *http.Request) (*Response, error) {
+				order = append(order, name+"-before")
+				req.Header.Set("X-Sign", req.Header.Get("X-Sign")+name)
+				resp, err := next(req)
+				order = append(order, name+"-after")
+				return resp, err
+			}
+		}
+	}
+
+	client := NewClient(WithMiddleware(mw("a"))).Use(mw("b"))
+	_, resp, err := client.NewRequest(http.MethodGet, s.URL).Use(mw("c")).String()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp != "abc" {
+		t.Fatalf("resp = %q, want abc", resp)
+	}
+
+	want := []string{"a-before", "b-before", "c-before", "c-after", "b-after", "a-after"}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+}
+
+func TestDecryptMiddleware(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"NAME":"AVA"}`))
+	}))
+	defer s.Close()
+
+	var val struct{ Name string }
+	err := NewClient().NewRequest(http.MethodGet, s.URL).UnmarshalWithDecrypt(func(s string) (string, error) {
+		return strings.ToLower(s), nil
+	}, &val)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if val.Name != "ava" {
+		t.Fatalf("name = %q, want ava", val.Name)
+	}
+
+	// 多次调用只解密一次
+	req := NewClient().NewRequest(http.MethodGet, s.URL)
+	wrap := func(s string) (string, error) { return "[" + s + "]", nil }
+	for i := 0; i < 2; i++ {
+		if _, resp, err := req.StringWithDecrypt(wrap); err != nil || resp != `[{"NAME":"AVA"}]` {
+			t.Fatalf("resp = %q, err = %v", resp, err)
+		}
+	}
+	if _, resp, _ := req.String(); resp != `{"NAME":"AVA"}` {
+		t.Fatalf("resp = %q, decrypt should not be kept on the request", resp)
+	}
+
+	statusCode, _, err := req.StringWithDecrypt(func(string) (string, error) { return "", errors.New("bad key") })
+	if err == nil || statusCode != http.StatusOK {
+		t.Fatalf("status = %d, err = %v", statusCode, err)
+	}
+}
+
+type testProxySelector struct {
+	invalid int
+}
+
+func (s *testProxySelector) ProxyFunc(req *http.Request) (*url.URL, error) {
+	return nil, nil
+}
+
+func (s *testProxySelector) ProxyInvalid(ctx context.Context) {
+	s.invalid++
+}
+
+func TestCheckProxyMiddleware(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer s.Close()
+
+	selector := &testProxySelector{}
+	client := NewClient(WithProxySelector(selector), WithCheckProxy(func(response *Response) bool {
+		return response.StatusCode() != http.StatusForbidden
+	}))
+	if _, err := client.NewRequest(http.MethodGet, s.URL).Do(); err != nil {
+		t.Fatal(err)
+	}
+
+	if selector.invalid != 1 {
+		t.Fatalf("invalid = %d, want 1", selector.invalid)
+	}
+}
@@ -0,0 +1,158 @@
+package httpclient
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// multipartField multipart/form-data 的一个字段 文件字段 filename 不为空
+type multipartField struct {
+	name     string
+	value    string
+	filename string
+	path     string    // 从文件路径读取
+	reader   io.Reader // 从 reader 读取
+}
+
+// SetFile 添加上传文件 请求以 multipart/form-data 格式发送 formData 作为文本字段一起发送
+// reader 不是 io.Seeker 时只能读取一次 这个请求不会重试 需要重试时使用 io.ReadSeeker 或者 SetFileFromPath
+func (r *Request) SetFile(field, filename string, reader io.Reader) *Request {
+	r.multipartFields = append(r.multipartFields, &multipartField{name: field, filename: filename, reader: reader})
+	return r
+}
+
+// SetFileFromPath 添加上传文件 发送时才打开文件
+func (r *Request) SetFileFromPath(field, path string) *Request {
+	r.multipartFields = append(r.multipartFields, &multipartField{name: field, filename: filepath.Base(path), path: path})
+	return r
+}
+
+// SetMultipartField 添加 multipart/form-data 文本字段 保持添加顺序
+func (r *Request) SetMultipartField(key, value string) *Request {
+	r.multipartFields = append(r.multipartFields, &multipartField{name: key, value: value})
+	return r
+}
+
+// SetMultipartFields 添加 multipart/form-data 文本字段 按 key 排序
+func (r *Request) SetMultipartFields(fields map[string]string) *Request {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		r.SetMultipartField(k, fields[k])
+	}
+	return r
+}
+
+// multipartRewindable 上传的文件都可以重新读取 不能 Seek 的 reader 不重试 避免读取到内存
+func (r *Request) multipartRewindable() bool {
+	for _, field := range r.multipartFields {
+		if _, ok := field.reader.(io.Seeker); field.reader != nil && !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// multipartBodyGetter 以流的方式构造 multipart/form-data body 不会把文件读取到内存
+// rewindable 为 true 时调用方保证 multipartRewindable
+func (r *Request) multipartBodyGetter(rewindable bool) (bodyGetter, error) {
+	opens := make([]bodyGetter, len(r.multipartFields))
+	for i, field := range r.multipartFields {
+		switch {
+		case field.path != "":
+			path := field.path
+			opens[i] = func() (io.Reader, error) {
+				return os.Open(path)
+			}
+		case field.reader != nil && rewindable:
+			open, err := rewindBody(field.reader)
+			if err != nil {
+				return nil, err
+			}
+			opens[i] = open
+		case field.reader != nil:
+			reader := field.reader
+			opens[i] = func() (io.Reader, error) {
+				return reader, nil
+			}
+		case field.filename != "":
+			opens[i] = func() (io.Reader, error) {
+				return bytes.NewReader(nil), nil
+			}
+		}
+	}
+
+	boundary := multipart.NewWriter(nil).Boundary()
+	r.heads.Set("Content-Type", MIMEMultipartPOSTForm+"; boundary="+boundary)
+
+	return func() (io.Reader, error) {
+		pr, pw := io.Pipe()
+		go func() {
+			mw := multipart.NewWriter(pw)
+			if err := mw.SetBoundary(boundary); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+
+			pw.CloseWithError(r.writeMultipart(mw, opens))
+		}()
+
+		return pr, nil
+	}, nil
+}
+
+func (r *Request) writeMultipart(mw *multipart.Writer, opens []bodyGetter) error {
+	keys := make([]string, 0, len(r.formData))
+	for key := range r.formData {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range r.formData[key] {
+			if err := mw.WriteField(key, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, field := range r.multipartFields {
+		if opens[i] == nil {
+			if err := mw.WriteField(field.name, field.value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := writeMultipartFile(mw, field, opens[i]); err != nil {
+			return err
+		}
+	}
+
+	return mw.Close()
+}
+
+func writeMultipartFile(mw *multipart.Writer, field *multipartField, open bodyGetter) error {
+	reader, err := open()
+	if err != nil {
+		return err
+	}
+
+	if closer, ok := reader.(io.Closer); ok && field.path != "" {
+		defer closer.Close()
+	}
+
+	part, err := mw.CreateFormFile(field.name, field.filename)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(part, reader)
+	return err
+}
@@ -0,0 +1,137 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/windzhu0514/go-utils/delayqueue/backoff"
+)
+
+func TestRequest_SetFile(t *testing.T) {
+	var attempts int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if got := r.FormValue("name"); got != "Ava" {
+			t.Errorf("name = %q, want Ava", got)
+		}
+		if got := r.FormValue("type"); got != "invoice" {
+			t.Errorf("type = %q, want invoice", got)
+		}
+
+		for field, want := range map[string]string{"invoice": "invoice content", "image": "image content"} {
+			f, header, err := r.FormFile(field)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			data, _ := io.ReadAll(f)
+			f.Close()
+			if string(data) != want {
+				t.Errorf("%s(%s) = %q, want %q", field, header.Filename, data, want)
+			}
+		}
+
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("OK"))
+	}))
+	defer s.Close()
+
+	path := filepath.Join(t.TempDir(), "image.png")
+	if err := os.WriteFile(path, []byte("image content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient(WithRetryCount(1), WithRetryBackoff(backoff.NewFixedPolicy(time.Millisecond, time.Millisecond)))
+	req := client.NewRequest(http.MethodPost, s.URL).
+		SetFormData("name", "Ava").
+		SetMultipartField("type", "invoice").
+		SetFile("invoice", "invoice.pdf", strings.NewReader("invoice content")).
+		SetFileFromPath("image", path)
+	code, resp, err := req.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusOK || resp != "OK" {
+		t.Fatalf("got %d %q, want 200 OK", code, resp)
+	}
+
+	if attempts != 2 {
+		t.Fatalf("attempts = %d, want 2", attempts)
+	}
+}
+
+func TestRequest_SetFileFromPathNotExist(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+	}))
+	defer s.Close()
+
+	_, err := NewClient().NewRequest(http.MethodPost, s.URL).SetFileFromPath("file", filepath.Join(t.TempDir(), "none")).Do()
+	if err == nil {
+		t.Fatal("want error for not exist file")
+	}
+}
+
+func TestRequest_SetFileNotSeekable(t *testing.T) {
+	var attempts int32
+	var bodies []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+
+	client := NewClient(WithRetryCount(2), WithRetryBackoff(backoff.NewFixedPolicy(time.Millisecond, time.Millisecond)))
+	send := func(reader io.Reader) {
+		t.Helper()
+		req := client.NewRequest(http.MethodPost, s.URL).
+			SetFormData("b", "2").
+			SetFormData("a", "1").
+			SetMultipartFields(map[string]string{"z": "26", "y": "25", "x": "24"}).
+			SetFile("file", "file.txt", reader)
+		resp, err := req.Do()
+		if err != nil || resp.StatusCode() != http.StatusServiceUnavailable {
+			t.Fatalf("status = %d, err = %v", resp.StatusCode(), err)
+		}
+	}
+
+	// 不能 Seek 的 reader 只发送一次
+	send(io.MultiReader(strings.NewReader("content")))
+	if attempts != 1 {
+		t.Fatalf("attempts = %d, want 1", attempts)
+	}
+	send(strings.NewReader("content"))
+	if attempts != 4 {
+		t.Fatalf("attempts = %d, want 4", attempts)
+	}
+
+	// 字段按固定的顺序写入
+	order := []string{`name="a"`, `name="b"`, `name="x"`, `name="y"`, `name="z"`, `name="file"`}
+	for _, body := range bodies {
+		last := -1
+		for _, name := range order {
+			i := strings.Index(body, name)
+			if i < last {
+				t.Fatalf("%s is out of order in %q", name, body)
+			}
+			last = i
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package httpclient
+
+import (
+	"net/http"
+
+	tls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2"
+)
+
+// Profile 浏览器或App的完整指纹 tls指纹、http2指纹、默认头部和头部顺序保持一致
+type Profile struct {
+	Name          string
+	ClientHelloID tls.ClientHelloID
+	HTTP2         *HTTP2Fingerprint
+	Headers       http.Header // 请求中没有设置时使用的头部
+	HeaderOrder   []string    // 头部顺序 小写
+}
+
+// 通过 Akamai 指纹字符串创建 HTTP2Fingerprint 预置的指纹不会出错
+func mustAkamai(fingerprint string, headerPriority *http2.PriorityParam) *HTTP2Fingerprint {
+	fp, err := ParseAkamaiFingerprint(fingerprint)
+	if err != nil {
+		panic(err)
+	}
+	fp.HeaderPriority = headerPriority
+	return fp
+}
+
+var (
+	// ProfileChrome Windows Chrome 106
+	ProfileChrome = &Profile{
+		Name:          "chrome",
+		ClientHelloID: tls.HelloChrome_106_Shuffle,
+		HTTP2: mustAkamai("1:65536;2:0;3:1000;4:6291456;6:262144|15663105|0|m,a,s,p",
+			&http2.PriorityParam{Exclusive: true, Weight: 255}),
+		Headers: http.Header{
+			"Sec-Ch-Ua":                 {`"Chromium";v="106", "Google Chrome";v="106", "Not;A=Brand";v="99"`},
+			"Sec-Ch-Ua-Mobile":          {"?0"},
+			"Sec-Ch-Ua-Platform":        {`"Windows"`},
+			"Upgrade-Insecure-Requests": {"1"},
+			"User-Agent":                {"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/106.0.0.0 Safari/537.36"},
+			"Accept":                    {"text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.9"},
+			"Sec-Fetch-Site":            {"none"},
+			"Sec-Fetch-Mode":            {"navigate"},
+			"Sec-Fetch-User":            {"?1"},
+			"Sec-Fetch-Dest":            {"document"},
+			"Accept-Encoding":           {"gzip, deflate, br"},
+			"Accept-Language":           {"zh-CN,zh;q=0.9,en;q=0.8"},
+		},
+		HeaderOrder: []string{
+			"host", "connection", "content-length", "cache-control",
+			"sec-ch-ua", "sec-ch-ua-mobile", "sec-ch-ua-platform", "upgrade-insecure-requests", "user-agent",
+			"content-type", "accept", "origin", "sec-fetch-site", "sec-fetch-mode", "sec-fetch-user", "sec-fetch-dest",
+			"referer", "accept-encoding", "accept-language", "cookie",
+		},
+	}
+
+	// ProfileEdge Windows Edge 85
+	ProfileEdge = &Profile{
+		Name:          "edge",
+		ClientHelloID: tls.HelloEdge_85,
+		HTTP2: mustAkamai("1:65536;3:1000;4:6291456;6:262144|15663105|0|m,a,s,p",
+			&http2.PriorityParam{Exclusive: true, Weight: 255}),
+		Headers: http.Header{
+			"Upgrade-Insecure-Requests": {"1"},
+			"User-Agent":                {"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/85.0.4183.102 Safari/537.36 Edg/85.0.564.51"},
+			"Accept":                    {"text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.9"},
+			"Sec-Fetch-Site":            {"none"},
+			"Sec-Fetch-Mode":            {"navigate"},
+			"Sec-Fetch-User":            {"?1"},
+			"Sec-Fetch-Dest":            {"document"},
+			"Accept-Encoding":           {"gzip, deflate, br"},
+			"Accept-Language":           {"zh-CN,zh;q=0.9,en;q=0.8"},
+		},
+		HeaderOrder: []string{
+			"host", "connection", "content-length", "cache-control", "upgrade-insecure-requests", "user-agent",
+			"content-type", "accept", "origin", "sec-fetch-site", "sec-fetch-mode", "sec-fetch-user", "sec-fetch-dest",
+			"referer", "accept-encoding", "accept-language", "cookie",
+		},
+	}
+
+	// ProfileFirefox Windows Firefox 105
+	ProfileFirefox = &Profile{
+		Name:          "firefox",
+		ClientHelloID: tls.HelloFirefox_105,
+		HTTP2: mustAkamai("1:65536;4:131072;5:16384|12517377|3:0:0:201,5:0:0:101,7:0:0:1,9:0:7:1,11:0:3:1,13:0:0:241|m,p,a,s",
+			&http2.PriorityParam{StreamDep: 13, Weight: 41}),
+		Headers: http.Header{
+			"User-Agent":                {"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:105.0) Gecko/20100101 Firefox/105.0"},
+			"Accept":                    {"text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8"},
+			"Accept-Language":           {"zh-CN,zh;q=0.8,zh-TW;q=0.7,zh-HK;q=0.5,en-US;q=0.3,en;q=0.2"},
+			"Accept-Encoding":           {"gzip, deflate, br"},
+			"Upgrade-Insecure-Requests": {"1"},
+			"Sec-Fetch-Dest":            {"document"},
+			"Sec-Fetch-Mode":            {"navigate"},
+			"Sec-Fetch-Site":            {"none"},
+			"Sec-Fetch-User":            {"?1"},
+		},
+		HeaderOrder: []string{
+			"host", "user-agent", "accept", "accept-language", "accept-encoding", "content-type", "content-length",
+			"origin", "connection", "referer", "cookie", "upgrade-insecure-requests",
+			"sec-fetch-dest", "sec-fetch-mode", "sec-fetch-site", "sec-fetch-user", "te",
+		},
+	}
+
+	// ProfileSafari macOS Safari 16
+	ProfileSafari = &Profile{
+		Name:          "safari",
+		ClientHelloID: tls.HelloSafari_16_0,
+		HTTP2: mustAkamai("4:4194304;3:100|10485760|0|m,s,p,a",
+			&http2.PriorityParam{Weight: 254}),
+		Headers: http.Header{
+			"Accept":          {"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+			"User-Agent":      {"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.0 Safari/605.1.15"},
+			"Accept-Language": {"zh-CN,zh-Hans;q=0.9"},
+			"Accept-Encoding": {"gzip, deflate, br"},
+		},
+		HeaderOrder: []string{
+			"host", "content-type", "origin", "cookie", "accept", "content-length", "user-agent",
+			"referer", "accept-language", "accept-encoding", "connection",
+		},
+	}
+
+	// ProfileSafariIOS iPhone iOS 14 Safari
+	ProfileSafariIOS = &Profile{
+		Name:          "safari_ios",
+		ClientHelloID: tls.HelloIOS_14,
+		HTTP2: mustAkamai("4:2097152;3:100|10485760|0|m,s,p,a",
+			&http2.PriorityParam{Weight: 254}),
+		Headers: http.Header{
+			"Accept":          {"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+			"User-Agent":      {"Mozilla/5.0 (iPhone; CPU iPhone OS 14_8 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.2 Mobile/15E148 Safari/604.1"},
+			"Accept-Language": {"zh-CN,zh-Hans;q=0.9"},
+			"Accept-Encoding": {"gzip, deflate, br"},
+		},
+		HeaderOrder: ProfileSafari.HeaderOrder,
+	}
+
+	// ProfileIOSApp iOS 14 原生App NSURLSession
+	ProfileIOSApp = &Profile{
+		Name:          "ios_app",
+		ClientHelloID: tls.HelloIOS_14,
+		HTTP2: mustAkamai("4:2097152;3:100|10485760|0|m,s,p,a",
+			&http2.PriorityParam{Weight: 254}),
+		Headers: http.Header{
+			"Accept":          {"*/*"},
+			"Accept-Language": {"zh-CN,zh-Hans;q=0.9"},
+			"Accept-Encoding": {"gzip, deflate, br"},
+			"User-Agent":      {"App/1 CFNetwork/1240.0.4 Darwin/20.6.0"},
+		},
+		HeaderOrder: []string{
+			"host", "content-type", "cookie", "accept", "content-length", "user-agent",
+			"accept-language", "accept-encoding", "connection",
+		},
+	}
+
+	// ProfileAndroidApp Android 11 原生App OkHttp
+	ProfileAndroidApp = &Profile{
+		Name:          "android_app",
+		ClientHelloID: tls.HelloAndroid_11_OkHttp,
+		HTTP2:         mustAkamai("4:16777216|16711681|0|m,p,a,s", nil),
+		Headers: http.Header{
+			"Accept-Encoding": {"gzip"},
+			"User-Agent":      {"okhttp/4.9.0"},
+		},
+		HeaderOrder: []string{
+			"host", "content-type", "content-length", "connection", "cookie", "accept-encoding", "user-agent",
+		},
+	}
+)
+
+// Profiles 所有预置的 Profile 按名字索引
+var Profiles = map[string]*Profile{
+	ProfileChrome.Name:     ProfileChrome,
+	ProfileEdge.Name:       ProfileEdge,
+	ProfileFirefox.Name:    ProfileFirefox,
+	ProfileSafari.Name:     ProfileSafari,
+	ProfileSafariIOS.Name:  ProfileSafariIOS,
+	ProfileIOSApp.Name:     ProfileIOSApp,
+	ProfileAndroidApp.Name: ProfileAndroidApp,
+}
+
+// http2Fingerprint 返回带头部顺序的 http2 指纹副本
+func (p *Profile) http2Fingerprint() *HTTP2Fingerprint {
+	if p.HTTP2 == nil {
+		return nil
+	}
+
+	fp := *p.HTTP2
+	if len(fp.HeaderOrder) == 0 {
+		fp.HeaderOrder = p.HeaderOrder
+	}
+	return &fp
+}
+
+// setDefaultHeaders 请求中没有的头部使用 Profile 的值
+func (p *Profile) setDefaultHeaders(header http.Header) {
+	for key, values := range p.Headers {
+		if _, ok := header[key]; !ok {
+			header[key] = values
+		}
+	}
+}
+
+// 使用浏览器 Profile 同时设置tls指纹、http2指纹和默认头部
+func WithTlsConnOptProfile(profile *Profile) TlsConnOption {
+	return func(tr *Transport) {
+		tr.profile = profile
+		tr.ClientHelloID = profile.ClientHelloID
+		tr.HTTP2Fingerprint = profile.http2Fingerprint()
+		if tr.HTTP2Fingerprint != nil {
+			tr.specErr = tr.HTTP2Fingerprint.validate()
+		}
+	}
+}
+
+// WithProfile 使用浏览器 Profile 的 Transport 其他 TlsConnOption 在 Profile 之后生效
+// 之前的选项设置的代理、连接数限制和 DNS 解析继续生效
+func WithProfile(profile *Profile, opts ...TlsConnOption) ClientOption {
+	return func(client *Client) {
+		tr := NewTransport(append([]TlsConnOption{WithTlsConnOptProfile(profile)}, opts...)...)
+		client.inheritTransport(tr)
+		client.client.Transport = tr
+	}
+}
+
+// inheritTransport 替换成 tr 前 把当前 Transport 的代理、连接数限制和 DNS 解析复制到 tr
+// tr 自己设置的代理和 DNS 解析优先
+func (c *Client) inheritTransport(tr *Transport) {
+	if tr.Proxy == nil {
+		tr.Proxy = c.proxyFunc
+	}
+
+	c.withResolver(tr)
+
+	var old *http.Transport
+	switch current := c.client.Transport.(type) {
+	case nil:
+		old = http.DefaultTransport.(*http.Transport)
+	case *Transport:
+		old = current.h1Transport
+	case *http.Transport:
+		old = current
+	default:
+		return
+	}
+	tr.h1Transport.MaxIdleConns = old.MaxIdleConns
+	tr.h1Transport.MaxIdleConnsPerHost = old.MaxIdleConnsPerHost
+	tr.h1Transport.MaxConnsPerHost = old.MaxConnsPerHost
+	tr.h1Transport.IdleConnTimeout = old.IdleConnTimeout
+}
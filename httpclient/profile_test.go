@@ -0,0 +1,76 @@
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	for name, profile := range Profiles {
+		tr := NewTransport(WithTlsConnOptProfile(profile))
+		if tr.specErr != nil {
+			t.Fatalf("%s: %v", name, tr.specErr)
+		}
+		if ja3, _ := tr.JA3(); ja3 == "" {
+			t.Errorf("%s: empty ja3", name)
+		}
+		if akamai, _ := tr.AkamaiFingerprint(); akamai != profile.HTTP2.String() {
+			t.Errorf("%s: akamai = %s", name, akamai)
+		}
+		if len(tr.HTTP2Fingerprint.HeaderOrder) == 0 {
+			t.Errorf("%s: header order not set", name)
+		}
+		for key := range profile.Headers {
+			if http.CanonicalHeaderKey(key) != key {
+				t.Errorf("%s: header %s not canonical", name, key)
+			}
+		}
+	}
+
+	if ProfileChrome.HTTP2.HeaderOrder != nil {
+		t.Fatal("preset modified by WithTlsConnOptProfile")
+	}
+}
+
+func TestWithProfile(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.UserAgent() + "|" + r.Header.Get("Accept-Language") + "|" + r.Header.Get("Sec-Ch-Ua-Mobile")))
+	}))
+	defer s.Close()
+
+	client := NewClient(WithProfile(ProfileChrome), WithMaxIdleConns(10))
+	tr, ok := client.client.Transport.(*Transport)
+	if !ok || tr.ClientHelloID != ProfileChrome.ClientHelloID {
+		t.Fatalf("transport = %T", client.client.Transport)
+	}
+
+	req := client.NewRequest(http.MethodGet, s.URL).SetHead("Accept-Language", "en")
+	_, body, err := req.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ProfileChrome.Headers.Get("User-Agent") + "|en|?0"
+	if body != want {
+		t.Fatalf("body = %s, want %s", body, want)
+	}
+
+	// 之前的选项设置的代理、连接数限制和 DNS 解析继续生效
+	proxy, _ := url.Parse(newFakeProxy(t, "proxy"))
+	selector, _ := NewProxyURLSelector(proxy, nil)
+	client = NewClient(WithProxySelector(selector), WithMaxConnsPerHost(3), WithProfile(ProfileChrome))
+	if _, body, err = client.NewRequest(http.MethodGet, "http://example.com/").String(); err != nil || body != "proxy" {
+		t.Fatalf("body = %q, err = %v", body, err)
+	}
+	if n := client.client.Transport.(*Transport).h1Transport.MaxConnsPerHost; n != 3 {
+		t.Fatalf("MaxConnsPerHost = %d", n)
+	}
+
+	_, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	client = NewClient(WithHostOverrides(map[string]string{"profile.test": "127.0.0.1"}), WithProfile(ProfileChrome))
+	if _, body, err = client.NewRequest(http.MethodGet, "http://profile.test:"+port).String(); err != nil || body == "" {
+		t.Fatalf("body = %q, err = %v", body, err)
+	}
+}
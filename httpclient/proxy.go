@@ -3,8 +3,12 @@ package httpclient
 import (
 	"context"
 	"errors"
+	"net"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
+	"time"
 )
 
 type ProxySelector interface {
@@ -12,6 +16,72 @@ type ProxySelector interface {
 	ProxyInvalid(ctx context.Context)
 }
 
+// ProxyReporter ProxySelector 可选实现 每次请求结束上报使用的代理的结果
+// err 为 nil 表示成功 checkProxy 返回 false 时 err 不为 nil
+type ProxyReporter interface {
+	ReportProxy(ctx context.Context, latency time.Duration, err error)
+}
+
+var errProxyCheckFailed = errors.New("httpclient: proxy check failed")
+
+var errContextProxyIgnored = errors.New("httpclient: per-request proxy needs a transport other than http.DefaultTransport")
+
+type proxyContextKey struct{}
+
+// NewProxyContext 返回携带代理的 context 使用该 context 的请求优先使用这个代理
+// 不会修改共用的 Transport 多个 goroutine 可以在同一个client上使用不同的代理
+func NewProxyContext(ctx context.Context, proxy *url.URL) context.Context {
+	return context.WithValue(ctx, proxyContextKey{}, proxy)
+}
+
+// contextProxyFunc 优先使用 context 中的代理 否则使用 fallback
+func contextProxyFunc(fallback func(req *http.Request) (*url.URL, error)) func(req *http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if proxy, ok := req.Context().Value(proxyContextKey{}).(*url.URL); ok && proxy != nil {
+			setContextProxy(req.Context(), proxy)
+			return proxy, nil
+		}
+
+		if fallback == nil {
+			return nil, nil
+		}
+		return fallback(req)
+	}
+}
+
+type proxySlotKey struct{}
+
+// proxySlot 记录一次请求实际使用的代理
+type proxySlot struct {
+	mu    sync.Mutex
+	proxy *url.URL
+}
+
+func withProxySlot(ctx context.Context) context.Context {
+	return context.WithValue(ctx, proxySlotKey{}, &proxySlot{})
+}
+
+// setContextProxy ProxyFunc 中记录选择的代理 请求不是通过 Request 发送时忽略
+func setContextProxy(ctx context.Context, proxy *url.URL) {
+	if slot, ok := ctx.Value(proxySlotKey{}).(*proxySlot); ok {
+		slot.mu.Lock()
+		slot.proxy = proxy
+		slot.mu.Unlock()
+	}
+}
+
+// ProxyFromContext 返回本次请求使用的代理 在 ProxyInvalid 中用于判断哪个代理失效
+func ProxyFromContext(ctx context.Context) *url.URL {
+	slot, ok := ctx.Value(proxySlotKey{}).(*proxySlot)
+	if !ok {
+		return nil
+	}
+
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	return slot.proxy
+}
+
 type ProxyURLSelector struct {
 	proxy        *url.URL
 	proxyInvalid func(ctx context.Context)
@@ -22,6 +92,7 @@ func NewProxyURLSelector(proxy *url.URL, proxyInvalid func(ctx context.Context))
 }
 
 func (s *ProxyURLSelector) ProxyFunc(req *http.Request) (*url.URL, error) {
+	setContextProxy(req.Context(), s.proxy)
 	return s.proxy, nil
 }
 
@@ -67,9 +138,93 @@ func (s *HostnameProxySelector) ProxyFunc(req *http.Request) (*url.URL, error) {
 		return nil, nil
 	}
 
+	setContextProxy(req.Context(), proxy)
 	return proxy, nil
 }
 
 func (s *HostnameProxySelector) ProxyInvalid(ctx context.Context) {
 	s.proxyInvalid(ctx)
 }
+
+// noProxyRules NO_PROXY 格式的规则 逗号分隔
+// * 匹配所有主机 IP 和 CIDR 匹配地址 example.com 匹配自身和子域名 .example.com 只匹配子域名
+// 规则可以带端口 如 example.com:8080
+type noProxyRules []noProxyRule
+
+type noProxyRule struct {
+	all    bool
+	ipNet  *net.IPNet
+	ip     net.IP
+	domain string
+	suffix bool // 以 . 开头 只匹配子域名
+	port   string
+}
+
+func parseNoProxy(noProxy string) noProxyRules {
+	var rules noProxyRules
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return noProxyRules{{all: true}}
+		}
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			rules = append(rules, noProxyRule{ipNet: ipNet})
+			continue
+		}
+
+		var rule noProxyRule
+		if host, port, err := net.SplitHostPort(entry); err == nil {
+			entry, rule.port = host, port
+		}
+		entry = strings.Trim(entry, "[]")
+		if ip := net.ParseIP(entry); ip != nil {
+			rule.ip = ip
+		} else {
+			rule.suffix = strings.HasPrefix(entry, ".")
+			rule.domain = strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// match 请求地址匹配任意一条规则时不使用代理
+func (rules noProxyRules) match(u *url.URL) bool {
+	if len(rules) == 0 || u == nil {
+		return false
+	}
+
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	ip := net.ParseIP(host)
+	for _, rule := range rules {
+		if rule.all {
+			return true
+		}
+		if rule.port != "" && rule.port != port {
+			continue
+		}
+		switch {
+		case rule.ipNet != nil:
+			if ip != nil && rule.ipNet.Contains(ip) {
+				return true
+			}
+		case rule.ip != nil:
+			if rule.ip.Equal(ip) {
+				return true
+			}
+		case host == rule.domain:
+			if !rule.suffix {
+				return true
+			}
+		case strings.HasSuffix(host, "."+rule.domain):
+			return true
+		}
+	}
+	return false
+}
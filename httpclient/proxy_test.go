@@ -0,0 +1,324 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRequest_SetProxy(t *testing.T) {
+	a, b := newFakeProxy(t, "a"), newFakeProxy(t, "b")
+
+	var invalid []string
+	var mu sync.Mutex
+	selector, _ := NewProxyURLSelector(nil, func(ctx context.Context) {
+		mu.Lock()
+		invalid = append(invalid, ProxyFromContext(ctx).String())
+		mu.Unlock()
+	})
+	client := NewClient(WithProxySelector(selector), WithCheckProxy(func(resp *Response) bool {
+		body, _ := resp.Body()
+		return string(body) != "b"
+	}))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			proxy, want := a, "a"
+			if i%2 == 1 {
+				proxy, want = b, "b"
+			}
+
+			_, body, err := client.NewRequest(http.MethodGet, "http://example.com/").SetProxy(proxy).String()
+			if err == nil && body != want {
+				err = fmt.Errorf("request %d: got %q, want %q", i, body, want)
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(invalid) != 10 || invalid[0] != b {
+		t.Fatalf("invalid = %v", invalid)
+	}
+
+	// 通过 context 指定代理
+	proxy, _ := url.Parse(a)
+	ctx := NewProxyContext(context.Background(), proxy)
+	if _, body, err := client.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/").String(); err != nil || body != "a" {
+		t.Fatalf("body = %q, err = %v", body, err)
+	}
+
+	// 没有指定代理时使用 selector 这里是直连
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("direct"))
+	}))
+	defer s.Close()
+	if _, body, err := client.NewRequest(http.MethodGet, s.URL).String(); err != nil || body != "direct" {
+		t.Fatalf("body = %q, err = %v", body, err)
+	}
+
+	// 外部传入的 *http.Transport
+	for _, c := range []*Client{
+		NewWithClient(&http.Client{Transport: &http.Transport{}}),
+		NewWithClient(nil),
+		NewClient(WithTransport(&http.Transport{})),
+		NewClient().SetTransport(&http.Transport{}),
+	} {
+		if _, body, err := c.NewRequest(http.MethodGet, "http://example.com/").SetProxy(a).String(); err != nil || body != "a" {
+			t.Fatalf("body = %q, err = %v", body, err)
+		}
+	}
+	// 复制后使用 不修改调用方的 Transport
+	shared := &http.Transport{}
+	hc := &http.Client{Transport: shared}
+	NewWithClient(hc)
+	NewClient(WithTransport(shared)).SetTransport(shared)
+	if shared.Proxy != nil || hc.Transport != shared {
+		t.Fatal("caller's transport modified")
+	}
+	if _, err := NewWithClient(&http.Client{}).NewRequest(http.MethodGet, "http://example.com/").SetProxy(a).Do(); !errors.Is(err, errContextProxyIgnored) {
+		t.Fatalf("err = %v, want errContextProxyIgnored", err)
+	}
+}
+
+// newSocksProxy 最简单的 socks4/socks5 代理 记录客户端请求的目标地址
+func newSocksProxy(t *testing.T, targets chan<- string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSocks(conn, targets)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveSocks(conn net.Conn, targets chan<- string) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	version, _ := br.ReadByte()
+
+	var target string
+	switch version {
+	case 4:
+		head := make([]byte, 7)
+		if _, err := io.ReadFull(br, head); err != nil {
+			return
+		}
+		_, _ = br.ReadString(0) // user id
+		port := strconv.Itoa(int(binary.BigEndian.Uint16(head[1:3])))
+		host := net.IP(head[3:7]).String()
+		if head[3] == 0 && head[4] == 0 && head[5] == 0 {
+			host, _ = br.ReadString(0)
+			host = strings.TrimSuffix(host, "\x00")
+		}
+		target = net.JoinHostPort(host, port)
+	case 5:
+		n, _ := br.ReadByte()
+		_, _ = io.ReadFull(br, make([]byte, n))
+		_, _ = conn.Write([]byte{5, 0})
+
+		head := make([]byte, 4)
+		if _, err := io.ReadFull(br, head); err != nil {
+			return
+		}
+		var host string
+		switch head[3] {
+		case 1:
+			ip := make([]byte, 4)
+			_, _ = io.ReadFull(br, ip)
+			host = net.IP(ip).String()
+		case 3:
+			n, _ := br.ReadByte()
+			name := make([]byte, n)
+			_, _ = io.ReadFull(br, name)
+			host = string(name)
+		case 4:
+			ip := make([]byte, 16)
+			_, _ = io.ReadFull(br, ip)
+			host = net.IP(ip).String()
+		}
+		port := make([]byte, 2)
+		_, _ = io.ReadFull(br, port)
+		target = net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+	default:
+		return
+	}
+	targets <- target
+
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+	if version == 4 {
+		_, _ = conn.Write([]byte{0, 0x5a, 0, 0, 0, 0, 0, 0})
+	} else {
+		_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	}
+
+	go func() {
+		_, _ = io.Copy(upstream, br)
+		upstream.Close()
+	}()
+	_, _ = io.Copy(conn, upstream)
+}
+
+// newConnectProxy http代理 支持 CONNECT 和转发请求
+func newConnectProxy(t *testing.T, targets chan<- string) string {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targets <- r.Host
+		if r.Method != http.MethodConnect {
+			_, _ = w.Write([]byte("proxy"))
+			return
+		}
+
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer upstream.Close()
+		conn, brw, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		_, _ = conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+		go func() {
+			_, _ = io.Copy(upstream, brw)
+			upstream.Close()
+		}()
+		_, _ = io.Copy(conn, upstream)
+	}))
+	t.Cleanup(s.Close)
+	return s.Listener.Addr().String()
+}
+
+func TestProxySchemes(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("origin"))
+	}))
+	defer s.Close()
+	_, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	localhost := net.JoinHostPort("localhost", port)
+	loopback := net.JoinHostPort("127.0.0.1", port)
+
+	targets := make(chan string, 10)
+	socks := newSocksProxy(t, targets)
+	connect := newConnectProxy(t, targets)
+
+	tests := []struct {
+		proxy  string
+		target string // 代理收到的目标地址
+		body   string
+	}{
+		{"socks4://" + socks, loopback, "origin"},
+		{"socks4a://" + socks, localhost, "origin"},
+		{"socks5://" + socks, loopback, "origin"},
+		{"socks5h://" + socks, localhost, "origin"},
+		{"http://" + connect, localhost, "proxy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.proxy, func(t *testing.T) {
+			proxyURL, _ := url.Parse(tt.proxy)
+			tr := NewTransport(WithTlsConnOptProxyFunc(http.ProxyURL(proxyURL)))
+
+			req, _ := http.NewRequest(http.MethodGet, "http://"+localhost+"/", nil)
+			resp, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != tt.body {
+				t.Fatalf("body = %q, want %q", body, tt.body)
+			}
+			if target := <-targets; target != tt.target {
+				t.Fatalf("proxy target = %s, want %s", target, tt.target)
+			}
+
+			// https 请求通过代理连接到目标后握手 目标不是tls服务所以握手失败
+			req, _ = http.NewRequest(http.MethodGet, "https://"+localhost+"/", nil)
+			_, err = tr.RoundTrip(req)
+			var dialErr *TLSDialError
+			if !errors.As(err, &dialErr) || dialErr.Op != "handshake" {
+				t.Fatalf("err = %v, want handshake error", err)
+			}
+			if target := <-targets; target != tt.target {
+				t.Fatalf("proxy target = %s, want %s", target, tt.target)
+			}
+		})
+	}
+
+	tr := NewTransport(WithTlsConnOptProxyAddr("ftp://" + socks))
+	req, _ := http.NewRequest(http.MethodGet, "https://"+localhost+"/", nil)
+	if _, err := tr.RoundTrip(req); err == nil || !strings.Contains(err.Error(), "unsupported proxy scheme") {
+		t.Fatalf("err = %v, want unsupported proxy scheme", err)
+	}
+}
+
+func TestNoProxy(t *testing.T) {
+	rules := parseNoProxy("localhost, .example.com,golang.org:8080,10.0.0.0/8,::1")
+	tests := []struct {
+		url   string
+		match bool
+	}{
+		{"http://localhost/", true},
+		{"http://example.com/", false},
+		{"http://www.example.com/", true},
+		{"http://golang.org:8080/", true},
+		{"http://golang.org/", false},
+		{"https://go.golang.org:8080/", true},
+		{"http://10.1.2.3/", true},
+		{"http://11.1.2.3/", false},
+		{"http://[::1]:8080/", true},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if got := rules.match(u); got != tt.match {
+			t.Errorf("match(%s) = %v, want %v", tt.url, got, tt.match)
+		}
+	}
+	if u, _ := url.Parse("http://any.host/"); !parseNoProxy("*").match(u) {
+		t.Error("* should match all hosts")
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("direct"))
+	}))
+	defer s.Close()
+
+	// 代理不可用 但是 127.0.0.1 不使用代理
+	tr := NewTransport(WithTlsConnOptProxyAddr("http://127.0.0.1:1"), WithTlsConnOptNoProxy("127.0.0.1"))
+	client := NewClient(WithTransport(tr))
+	if _, body, err := client.NewRequest(http.MethodGet, s.URL).String(); err != nil || body != "direct" {
+		t.Fatalf("body = %q, err = %v", body, err)
+	}
+}
@@ -0,0 +1,527 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoProxyAvailable 代理池中没有可用的代理
+var ErrNoProxyAvailable = errors.New("httpclient: no proxy available")
+
+// ProxyStrategy 代理池选择代理的策略
+type ProxyStrategy int
+
+const (
+	ProxyRoundRobin   ProxyStrategy = iota // 轮询
+	ProxyRandom                            // 随机
+	ProxyWeighted                          // 按权重平滑轮询
+	ProxyStickyByHost                      // 同一个 host 固定使用一个代理 代理失效后重新分配
+)
+
+// ProxyProbe 检测代理是否恢复 返回 nil 表示可用
+type ProxyProbe func(ctx context.Context, proxy *url.URL) error
+
+// ProxyStats 代理的统计信息
+type ProxyStats struct {
+	URL              *url.URL
+	Weight           int
+	Available        bool
+	Requests         int64
+	Successes        int64
+	Failures         int64
+	SuccessRate      float64       // 没有请求时为0
+	Latency          time.Duration // 成功请求的平均延迟
+	LastError        string
+	QuarantinedUntil time.Time // 隔离中的代理到该时间后才会检测
+}
+
+type ProxyPoolOption func(*ProxyPool)
+
+// WithProxyStrategy 设置选择策略 默认轮询
+func WithProxyStrategy(strategy ProxyStrategy) ProxyPoolOption {
+	return func(p *ProxyPool) {
+		p.strategy = strategy
+	}
+}
+
+// WithProxyQuarantine 设置代理失效后的隔离时间 默认30秒
+func WithProxyQuarantine(d time.Duration) ProxyPoolOption {
+	return func(p *ProxyPool) {
+		p.quarantine = d
+	}
+}
+
+// WithProxyMaxFails 连续失败多少次后隔离代理 默认3次 ProxyInvalid 会立即隔离
+func WithProxyMaxFails(n int) ProxyPoolOption {
+	return func(p *ProxyPool) {
+		p.maxFails = n
+	}
+}
+
+// WithProxyProbe 设置检测函数 隔离时间到后检测通过才恢复 未设置时到期直接恢复
+func WithProxyProbe(probe ProxyProbe) ProxyPoolOption {
+	return func(p *ProxyPool) {
+		p.probe = probe
+	}
+}
+
+// WithProxyProbeURL 通过代理请求 probeURL 返回非5xx即认为代理可用
+func WithProxyProbeURL(probeURL string, timeout time.Duration) ProxyPoolOption {
+	return WithProxyProbe(func(ctx context.Context, proxy *url.URL) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL, nil)
+		if err != nil {
+			return err
+		}
+
+		transport := &http.Transport{Proxy: http.ProxyURL(proxy), DisableKeepAlives: true}
+		defer transport.CloseIdleConnections()
+
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("httpclient: probe status %d", resp.StatusCode)
+		}
+		return nil
+	})
+}
+
+// WithProxyProbeInterval 设置后台检测隔离代理的间隔 默认5秒
+func WithProxyProbeInterval(d time.Duration) ProxyPoolOption {
+	return func(p *ProxyPool) {
+		p.probeInterval = d
+	}
+}
+
+// WithProxyOnInvalid 代理被隔离时回调
+func WithProxyOnInvalid(onInvalid func(ctx context.Context, proxy *url.URL)) ProxyPoolOption {
+	return func(p *ProxyPool) {
+		p.onInvalid = onInvalid
+	}
+}
+
+type poolProxy struct {
+	url    *url.URL
+	weight int
+
+	// 平滑加权轮询的当前权重
+	currentWeight int
+
+	requests         int64
+	successes        int64
+	failures         int64
+	consecutiveFails int
+	totalLatency     time.Duration
+	lastError        string
+
+	quarantinedUntil time.Time
+	quarantined      bool
+	probing          bool
+}
+
+func (p *poolProxy) stats() ProxyStats {
+	s := ProxyStats{
+		URL:              p.url,
+		Weight:           p.weight,
+		Available:        !p.quarantined,
+		Requests:         p.requests,
+		Successes:        p.successes,
+		Failures:         p.failures,
+		LastError:        p.lastError,
+		QuarantinedUntil: p.quarantinedUntil,
+	}
+	if p.requests > 0 {
+		s.SuccessRate = float64(p.successes) / float64(p.requests)
+	}
+	if p.successes > 0 {
+		s.Latency = p.totalLatency / time.Duration(p.successes)
+	}
+	return s
+}
+
+// ProxyPool 带健康检查的轮换代理池 实现 ProxySelector 和 ProxyReporter
+// 代理失效时隔离一段时间 后台检测通过后恢复
+// 通过 Request 发送的请求才能知道使用了哪个代理 直接作为 http.Transport.Proxy 使用时不会统计和隔离
+type ProxyPool struct {
+	mu      sync.Mutex
+	proxies []*poolProxy
+	index   map[string]*poolProxy
+	next    int
+	sticky  map[string]*poolProxy
+	rand    *rand.Rand
+
+	strategy      ProxyStrategy
+	quarantine    time.Duration
+	maxFails      int
+	probe         ProxyProbe
+	probeInterval time.Duration
+	onInvalid     func(ctx context.Context, proxy *url.URL)
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewProxyPool 创建代理池并启动后台检测 不再使用时调用 Close
+func NewProxyPool(opts ...ProxyPoolOption) *ProxyPool {
+	p := &ProxyPool{
+		index:         make(map[string]*poolProxy),
+		sticky:        make(map[string]*poolProxy),
+		rand:          rand.New(rand.NewSource(time.Now().UnixNano())),
+		quarantine:    30 * time.Second,
+		maxFails:      3,
+		probeInterval: 5 * time.Second,
+		done:          make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if p.probeInterval > 0 {
+		go p.probeLoop()
+	}
+
+	return p
+}
+
+// Close 停止后台检测
+func (p *ProxyPool) Close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+}
+
+// Add 添加代理 没有 scheme 时默认为 http weight 小于1时为1 已存在时更新权重
+func (p *ProxyPool) Add(rawURL string, weight int) error {
+	proxy, err := parseProxyURL(rawURL)
+	if err != nil {
+		return err
+	}
+
+	p.AddURL(proxy, weight)
+	return nil
+}
+
+// AddURL 添加代理 已存在时更新权重
+func (p *ProxyPool) AddURL(proxy *url.URL, weight int) {
+	if weight < 1 {
+		weight = 1
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pp, ok := p.index[proxy.String()]; ok {
+		pp.weight = weight
+		return
+	}
+
+	pp := &poolProxy{url: proxy, weight: weight}
+	p.proxies = append(p.proxies, pp)
+	p.index[proxy.String()] = pp
+}
+
+// Remove 移除代理
+func (p *ProxyPool) Remove(rawURL string) {
+	proxy, err := parseProxyURL(rawURL)
+	if err != nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pp, ok := p.index[proxy.String()]
+	if !ok {
+		return
+	}
+
+	delete(p.index, proxy.String())
+	for i := range p.proxies {
+		if p.proxies[i] == pp {
+			p.proxies = append(p.proxies[:i], p.proxies[i+1:]...)
+			break
+		}
+	}
+	for host, sticky := range p.sticky {
+		if sticky == pp {
+			delete(p.sticky, host)
+		}
+	}
+}
+
+// Load 批量加载代理 每行一个代理 可以在代理后跟空白和权重 忽略空行和 # 开头的注释
+func (p *ProxyPool) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		weight := 1
+		if len(fields) > 1 {
+			w, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return fmt.Errorf("httpclient: proxy line %d: invalid weight %q", lineNo, fields[1])
+			}
+			weight = w
+		}
+
+		if err := p.Add(fields[0], weight); err != nil {
+			return fmt.Errorf("httpclient: proxy line %d: %w", lineNo, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// LoadFile 从文件批量加载代理 格式同 Load
+func (p *ProxyPool) LoadFile(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return p.Load(f)
+}
+
+// Stats 返回所有代理的统计信息 按添加顺序
+func (p *ProxyPool) Stats() []ProxyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make([]ProxyStats, 0, len(p.proxies))
+	for _, pp := range p.proxies {
+		stats = append(stats, pp.stats())
+	}
+	return stats
+}
+
+// ProxyFunc 实现ProxySelector接口 没有可用代理时返回 ErrNoProxyAvailable
+func (p *ProxyPool) ProxyFunc(req *http.Request) (*url.URL, error) {
+	p.mu.Lock()
+	pp := p.pick(req)
+	p.mu.Unlock()
+
+	if pp == nil {
+		return nil, ErrNoProxyAvailable
+	}
+
+	setContextProxy(req.Context(), pp.url)
+	return pp.url, nil
+}
+
+// pick 按策略选择一个可用代理 调用时持有锁
+func (p *ProxyPool) pick(req *http.Request) *poolProxy {
+	available := make([]*poolProxy, 0, len(p.proxies))
+	for _, pp := range p.proxies {
+		if !pp.quarantined {
+			available = append(available, pp)
+		}
+	}
+	if len(available) == 0 {
+		return nil
+	}
+
+	switch p.strategy {
+	case ProxyRandom:
+		return available[p.rand.Intn(len(available))]
+	case ProxyWeighted:
+		return smoothWeighted(available)
+	case ProxyStickyByHost:
+		var host string
+		if req != nil && req.URL != nil {
+			host = req.URL.Host
+		}
+		if pp, ok := p.sticky[host]; ok && !pp.quarantined {
+			return pp
+		}
+		pp := p.roundRobin(available)
+		p.sticky[host] = pp
+		return pp
+	default:
+		return p.roundRobin(available)
+	}
+}
+
+func (p *ProxyPool) roundRobin(available []*poolProxy) *poolProxy {
+	pp := available[p.next%len(available)]
+	p.next++
+	return pp
+}
+
+// smoothWeighted nginx 平滑加权轮询
+func smoothWeighted(available []*poolProxy) *poolProxy {
+	var (
+		best  *poolProxy
+		total int
+	)
+	for _, pp := range available {
+		pp.currentWeight += pp.weight
+		total += pp.weight
+		if best == nil || pp.currentWeight > best.currentWeight {
+			best = pp
+		}
+	}
+	best.currentWeight -= total
+	return best
+}
+
+// ProxyInvalid 实现ProxySelector接口 立即隔离本次请求使用的代理
+func (p *ProxyPool) ProxyInvalid(ctx context.Context) {
+	proxy := ProxyFromContext(ctx)
+	if proxy == nil {
+		return
+	}
+
+	p.mu.Lock()
+	pp, ok := p.index[proxy.String()]
+	quarantined := ok && p.quarantineLocked(pp)
+	p.mu.Unlock()
+
+	if quarantined && p.onInvalid != nil {
+		p.onInvalid(ctx, proxy)
+	}
+}
+
+// ReportProxy 实现ProxyReporter接口 统计成功率和延迟 连续失败达到 maxFails 时隔离
+// 上下文取消导致的失败不计入统计
+func (p *ProxyPool) ReportProxy(ctx context.Context, latency time.Duration, err error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+
+	proxy := ProxyFromContext(ctx)
+	if proxy == nil {
+		return
+	}
+
+	p.mu.Lock()
+	pp, ok := p.index[proxy.String()]
+	if !ok {
+		p.mu.Unlock()
+		return
+	}
+
+	pp.requests++
+	quarantined := false
+	if err == nil {
+		pp.successes++
+		pp.consecutiveFails = 0
+		pp.totalLatency += latency
+	} else {
+		pp.failures++
+		pp.consecutiveFails++
+		pp.lastError = err.Error()
+		if p.maxFails > 0 && pp.consecutiveFails >= p.maxFails {
+			quarantined = p.quarantineLocked(pp)
+		}
+	}
+	p.mu.Unlock()
+
+	if quarantined && p.onInvalid != nil {
+		p.onInvalid(ctx, proxy)
+	}
+}
+
+// quarantineLocked 隔离代理 已经隔离时返回 false
+func (p *ProxyPool) quarantineLocked(pp *poolProxy) bool {
+	if pp.quarantined {
+		return false
+	}
+
+	pp.quarantined = true
+	pp.quarantinedUntil = time.Now().Add(p.quarantine)
+	return true
+}
+
+func (p *ProxyPool) probeLoop() {
+	ticker := time.NewTicker(p.probeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.ProbeNow(context.Background())
+		}
+	}
+}
+
+// ProbeNow 检测隔离时间已到的代理 检测通过的恢复使用 失败的继续隔离
+func (p *ProxyPool) ProbeNow(ctx context.Context) {
+	now := time.Now()
+
+	p.mu.Lock()
+	var due []*poolProxy
+	for _, pp := range p.proxies {
+		if pp.quarantined && !pp.probing && !now.Before(pp.quarantinedUntil) {
+			pp.probing = true
+			due = append(due, pp)
+		}
+	}
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, pp := range due {
+		wg.Add(1)
+		go func(pp *poolProxy) {
+			defer wg.Done()
+
+			var err error
+			if p.probe != nil {
+				err = p.probe(ctx, pp.url)
+			}
+
+			p.mu.Lock()
+			pp.probing = false
+			if err == nil {
+				pp.quarantined = false
+				pp.quarantinedUntil = time.Time{}
+				pp.consecutiveFails = 0
+			} else {
+				pp.lastError = err.Error()
+				pp.quarantinedUntil = time.Now().Add(p.quarantine)
+			}
+			p.mu.Unlock()
+		}(pp)
+	}
+	wg.Wait()
+}
+
+func parseProxyURL(rawURL string) (*url.URL, error) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+
+	proxy, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if proxy.Host == "" {
+		return nil, fmt.Errorf("httpclient: invalid proxy %q", rawURL)
+	}
+
+	return proxy, nil
+}
@@ -0,0 +1,143 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFakeProxy 直接返回代理名称的http代理
+func newFakeProxy(t *testing.T, name string) string {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(name))
+	}))
+	t.Cleanup(s.Close)
+	return s.URL
+}
+
+func poolRequest(req *http.Request) *http.Request {
+	return req.WithContext(withProxySlot(req.Context()))
+}
+
+func TestProxyPool_Strategies(t *testing.T) {
+	tests := []struct {
+		strategy ProxyStrategy
+		hosts    []string
+		want     string
+	}{
+		{ProxyRoundRobin, []string{"a.com", "a.com", "a.com", "a.com"}, "1 2 3 1"},
+		{ProxyWeighted, []string{"a.com", "a.com", "a.com", "a.com", "a.com"}, "1 2 1 3 1"},
+		{ProxyStickyByHost, []string{"a.com", "b.com", "a.com", "b.com"}, "1 2 1 2"},
+	}
+
+	for _, tt := range tests {
+		pool := NewProxyPool(WithProxyStrategy(tt.strategy), WithProxyProbeInterval(0))
+		if err := pool.Load(strings.NewReader("# proxies\n1.1.1.1:1 3\nhttp://2.2.2.2:2\n\nsocks5://3.3.3.3:3 1\n")); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, host := range tt.hosts {
+			req, _ := http.NewRequest(http.MethodGet, "http://"+host+"/", nil)
+			proxy, err := pool.ProxyFunc(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, proxy.Port())
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("strategy %d: got %v, want %s", tt.strategy, got, tt.want)
+		}
+	}
+}
+
+func TestProxyPool_Quarantine(t *testing.T) {
+	bad, good := newFakeProxy(t, "bad"), newFakeProxy(t, "good")
+
+	probed := make(chan string, 1)
+	var invalid []string
+	pool := NewProxyPool(
+		WithProxyProbeInterval(0),
+		WithProxyQuarantine(0),
+		WithProxyProbe(func(ctx context.Context, proxy *url.URL) error {
+			probed <- proxy.String()
+			return nil
+		}),
+		WithProxyOnInvalid(func(ctx context.Context, proxy *url.URL) {
+			invalid = append(invalid, proxy.String())
+		}),
+	)
+	defer pool.Close()
+	_ = pool.Add(bad, 1)
+	_ = pool.Add(good, 1)
+
+	client := NewClient(WithProxySelector(pool), WithCheckProxy(func(resp *Response) bool {
+		body, _ := resp.Body()
+		return string(body) != "bad"
+	}))
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		_, body, err := client.NewRequest(http.MethodGet, "http://example.com/").String()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, body)
+	}
+	if strings.Join(got, " ") != "bad good good good" {
+		t.Fatalf("got %v", got)
+	}
+	if len(invalid) != 1 || invalid[0] != bad {
+		t.Fatalf("invalid = %v", invalid)
+	}
+
+	stats := pool.Stats()
+	if stats[0].Available || stats[0].Failures != 1 || stats[0].SuccessRate != 0 {
+		t.Fatalf("bad stats = %+v", stats[0])
+	}
+	if !stats[1].Available || stats[1].Successes != 3 || stats[1].SuccessRate != 1 || stats[1].Latency <= 0 {
+		t.Fatalf("good stats = %+v", stats[1])
+	}
+
+	pool.ProbeNow(context.Background())
+	if p := <-probed; p != bad {
+		t.Fatalf("probed %s", p)
+	}
+	if !pool.Stats()[0].Available {
+		t.Fatal("proxy not restored after probe")
+	}
+}
+
+func TestProxyPool_MaxFails(t *testing.T) {
+	pool := NewProxyPool(WithProxyProbeInterval(0), WithProxyMaxFails(2), WithProxyQuarantine(time.Hour),
+		WithProxyProbe(func(ctx context.Context, proxy *url.URL) error { return errors.New("down") }))
+	_ = pool.Add("1.1.1.1:1", 1)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req = poolRequest(req)
+	if _, err := pool.ProxyFunc(req); err != nil {
+		t.Fatal(err)
+	}
+
+	pool.ReportProxy(req.Context(), 0, context.Canceled)
+	pool.ReportProxy(req.Context(), 0, errors.New("refused"))
+	if !pool.Stats()[0].Available {
+		t.Fatal("quarantined before max fails")
+	}
+	pool.ReportProxy(req.Context(), 0, errors.New("refused"))
+
+	if _, err := pool.ProxyFunc(req); !errors.Is(err, ErrNoProxyAvailable) {
+		t.Fatalf("err = %v, want ErrNoProxyAvailable", err)
+	}
+
+	// 隔离时间未到 不检测
+	pool.ProbeNow(context.Background())
+	if s := pool.Stats()[0]; s.Available || s.LastError != "refused" || s.Requests != 2 {
+		t.Fatalf("stats = %+v", s)
+	}
+}
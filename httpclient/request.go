@@ -9,6 +9,7 @@ import (
 	stdurl "net/url"
 	"strings"
 
+	"github.com/windzhu0514/go-utils/delayqueue/backoff"
 	"github.com/windzhu0514/go-utils/httpclient/metadata"
 )
 
@@ -29,6 +30,13 @@ type Request struct {
 	jsonIndentPrefix, jsonIndentValue string
 	checkRedirect                     func(req *http.Request, via []*http.Request) error
 	checkProxy                        func(response *Response) bool
+	retryCount                        int
+	retryBackoff                      backoff.Policy
+	retryConditions                   []RetryCondition
+	middlewares                       []Middleware
+	multipartFields                   []*multipartField
+	proxy                             string
+	headerOrder, pseudoHeaderOrder    []string
 }
 
 type DecryptFunc = func(string) (string, error)
@@ -134,6 +142,48 @@ func (r *Request) SetCheckRedirect(checkRedirect func(req *http.Request, via []*
 	return r
 }
 
+// SetHeaderOrder 设置头部的发送顺序 只有 httpclient.Transport 支持 不在顺序中的头部放在后面
+func (r *Request) SetHeaderOrder(order []string) *Request {
+	r.headerOrder = order
+	return r
+}
+
+// SetPseudoHeaderOrder 设置http2伪头部的顺序 如 :method :authority :scheme :path
+func (r *Request) SetPseudoHeaderOrder(order []string) *Request {
+	r.pseudoHeaderOrder = order
+	return r
+}
+
+// SetOrderedHeads 按顺序设置头部 同时设置头部顺序
+func (r *Request) SetOrderedHeads(heads [][2]string) *Request {
+	order := make([]string, 0, len(heads))
+	for _, head := range heads {
+		r.heads.Del(head[0])
+	}
+	for _, head := range heads {
+		r.heads.Add(head[0], head[1])
+		order = append(order, head[0])
+	}
+	return r.SetHeaderOrder(order)
+}
+
+// SetProxy 本次请求使用指定代理 不影响同一个client的其他请求
+// 没有 scheme 时默认为 http 代理 连接池按代理区分连接
+// client 使用 http.DefaultTransport 时无法按请求设置代理 Do 返回错误
+func (r *Request) SetProxy(proxy string) *Request {
+	r.proxy = proxy
+	return r
+}
+
+// SetProxyURL 同 SetProxy
+func (r *Request) SetProxyURL(proxy *stdurl.URL) *Request {
+	r.proxy = ""
+	if proxy != nil {
+		r.proxy = proxy.String()
+	}
+	return r
+}
+
 // SetCheckProxy 设置代理检查函数
 func (r *Request) SetCheckProxy(checkProxy func(response *Response) bool) *Request {
 	r.checkProxy = checkProxy
@@ -168,6 +218,7 @@ func (r *Request) AddCookies(cookies []*http.Cookie) *Request {
 }
 
 // SetBody 设置body
+// body 不是 io.Reader、[]byte 和 string 时按 contentType 对应的 Codec 编码 没有对应的 Codec 时使用json编码
 func (r *Request) SetBody(contentType string, body interface{}) *Request {
 	r.SetHead("Content-Type", contentType)
 	r.body = body
@@ -193,50 +244,81 @@ func (r *Request) CustomRequest(f func(request *Request)) *Request {
 	return r
 }
 
-// Do TODO: retry
+// Do 发送请求 设置了重试次数时按重试条件和退避策略重试
 func (r *Request) Do() (*Response, error) {
 	if r.checkRedirect != nil {
 		r.client.client.CheckRedirect = r.checkRedirect
 	}
 
-	var body io.Reader
-	if len(r.formData) > 0 {
-		body = bytes.NewBuffer([]byte(r.formData.Encode()))
-	} else if r.body != nil {
-		switch data := r.body.(type) {
-		case io.Reader:
-			body = data
-		case []byte:
-			body = bytes.NewReader(data)
-		case string:
-			body = strings.NewReader(data)
-		default:
-			buf := bytes.NewBuffer(nil)
-			enc := json.NewEncoder(buf)
-			if r.jsonEscapeHTML || r.client.jsonEscapeHTML {
-				enc.SetEscapeHTML(true)
-			}
+	retryCount := r.getRetryCount()
+	if retryCount > 0 && !r.multipartRewindable() {
+		// 不能 Seek 的上传文件只能发送一次
+		retryCount = 0
+	}
+	getBody, err := r.bodyGetter(retryCount > 0)
+	if err != nil {
+		return nil, err
+	}
 
-			jsonIndentPrefix := r.client.jsonIndentPrefix
-			jsonIndentValue := r.client.jsonIndentValue
+	if closer, ok := r.body.(io.Closer); ok && retryCount > 0 {
+		defer closer.Close()
+	}
 
-			if r.jsonIndentPrefix != "" {
-				jsonIndentPrefix = r.jsonIndentPrefix
-			}
+	reqURL, err := r.buildURL()
+	if err != nil {
+		return nil, err
+	}
 
-			if r.jsonIndentValue != "" {
-				jsonIndentValue = r.jsonIndentValue
-			}
+	// 查询参数已经合并到 url 中 再次发送时不会重复添加
+	r.url = reqURL.String()
+	r.queryParam = make(stdurl.Values)
+	r.queryParamKeys = nil
 
-			enc.SetIndent(jsonIndentPrefix, jsonIndentValue)
+	ctx := metadata.NewRequestContext(r.ctx, r.requestMetadata())
+	if r.proxy != "" {
+		if r.client.contextProxyIgnored() {
+			return nil, errContextProxyIgnored
+		}
+		proxy, err := parseProxyURL(r.proxy)
+		if err != nil {
+			return nil, err
+		}
+		ctx = NewProxyContext(ctx, proxy)
+	}
+	if len(r.headerOrder) > 0 || len(r.pseudoHeaderOrder) > 0 {
+		ctx = NewHeaderOrderContext(ctx, r.headerOrder, r.pseudoHeaderOrder)
+	}
 
-			if err := enc.Encode(data); err != nil {
-				return nil, err
-			}
-			body = buf
+	ctx, span := r.client.tracing.start(ctx, r.method, reqURL)
+	resp, err := r.doRetry(ctx, getBody, retryCount)
+	if resp != nil {
+		resp.req = r
+		endSpan(span, resp.resp, err)
+	} else {
+		endSpan(span, nil, err)
+	}
+	return resp, err
+}
+
+// doRetry 发送请求 按重试条件和退避策略重试
+func (r *Request) doRetry(ctx context.Context, getBody bodyGetter, retryCount int) (*Response, error) {
+	for attempts := 1; ; attempts++ {
+		resp, err := r.do(ctx, getBody)
+		if attempts > retryCount || !r.shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		wait := r.retryWait(attempts, resp)
+		resp.discard()
+		if sleepErr := sleepContext(ctx, wait); sleepErr != nil {
+			// 响应的 body 已经关闭 不再返回
+			return nil, sleepErr
 		}
 	}
+}
 
+// buildURL 把查询参数添加到 url 中
+func (r *Request) buildURL() (*stdurl.URL, error) {
 	reqURL, err := stdurl.Parse(r.url)
 	if err != nil {
 		return nil, err
@@ -269,22 +351,119 @@ func (r *Request) Do() (*Response, error) {
 			reqURL.RawQuery = reqURL.RawQuery + "&" + queryParam
 		}
 	}
+	return reqURL, nil
+}
 
-	r.url = reqURL.String()
+// requestMetadata 合并 client 和请求 context 中的 metadata 请求 context 中的优先
+func (r *Request) requestMetadata() metadata.Metadata {
+	md := r.client.metadata.Clone()
+	if ctxMD, ok := metadata.FromRequestContext(r.ctx); ok {
+		for k, v := range ctxMD {
+			md.Set(k, v)
+		}
+	}
+	return md
+}
+
+// bodyGetter 构造请求 body rewindable 为 true 时 io.Reader 类型的 body 可以重复读取
+func (r *Request) bodyGetter(rewindable bool) (bodyGetter, error) {
+	if len(r.multipartFields) > 0 {
+		return r.multipartBodyGetter(rewindable)
+	}
+
+	var data []byte
+	if len(r.formData) > 0 {
+		data = []byte(r.formData.Encode())
+	} else if r.body != nil {
+		switch body := r.body.(type) {
+		case io.Reader:
+			if rewindable {
+				return rewindBody(body)
+			}
+			// 不重试时直接使用，只能读取一次
+			return func() (io.Reader, error) {
+				return body, nil
+			}, nil
+		case []byte:
+			data = body
+		case string:
+			data = []byte(body)
+		default:
+			if codec := GetCodec(r.heads.Get("Content-Type")); codec != nil {
+				if _, ok := codec.(jsonCodec); !ok {
+					var err error
+					if data, err = codec.Marshal(body); err != nil {
+						return nil, err
+					}
+					break
+				}
+			}
+
+			// 默认使用json编码
+			buf := bytes.NewBuffer(nil)
+			enc := json.NewEncoder(buf)
+			if r.jsonEscapeHTML || r.client.jsonEscapeHTML {
+				enc.SetEscapeHTML(true)
+			}
+
+			jsonIndentPrefix := r.client.jsonIndentPrefix
+			jsonIndentValue := r.client.jsonIndentValue
+
+			if r.jsonIndentPrefix != "" {
+				jsonIndentPrefix = r.jsonIndentPrefix
+			}
+
+			if r.jsonIndentValue != "" {
+				jsonIndentValue = r.jsonIndentValue
+			}
 
-	ctx := metadata.NewRequestContext(r.ctx, r.client.metadata)
-	req, err := http.NewRequestWithContext(ctx, r.method, r.url, body)
+			enc.SetIndent(jsonIndentPrefix, jsonIndentValue)
+
+			if err := enc.Encode(body); err != nil {
+				return nil, err
+			}
+			data = buf.Bytes()
+		}
+	}
+
+	return func() (io.Reader, error) {
+		if data == nil {
+			return nil, nil
+		}
+		return bytes.NewReader(data), nil
+	}, nil
+}
+
+// do 发送一次请求
+func (r *Request) do(ctx context.Context, getBody bodyGetter) (*Response, error) {
+	body, err := getBody()
 	if err != nil {
 		return nil, err
 	}
 
+	req, err := http.NewRequestWithContext(withProxySlot(ctx), r.method, r.url, body)
+	if err != nil {
+		if closer, ok := body.(io.Closer); ok {
+			closer.Close()
+		}
+		return nil, err
+	}
+	if lr, ok := body.(*lengthReader); ok {
+		req.ContentLength = lr.length
+		if lr.length == 0 {
+			req.Body = http.NoBody
+		}
+	}
+
 	for key, value := range r.heads {
 		req.Header[key] = value
 	}
+	setMetadataHeaders(ctx, req.Header, r.client.metadataHeaders)
+	r.client.tracing.inject(ctx, req.Header)
 
-	if len(r.client.cookies) > 0 {
+	if cookies := r.client.clientCookies(); len(cookies) > 0 {
 		if r.client.client.Jar != nil {
-			r.client.client.Jar.SetCookies(req.URL, r.client.cookies)
+			r.client.client.Jar.SetCookies(req.URL, cookies)
 		}
 	}
 
@@ -294,17 +473,36 @@ func (r *Request) Do() (*Response, error) {
 		}
 	}
 
-	var resp Response
-	resp.resp, err = r.client.client.Do(req)
+	return chainMiddleware(r.roundTrip, r.middlewareChain()...)(req)
+}
+
+// middlewareChain 依次为client中间件、请求中间件和代理检查
+func (r *Request) middlewareChain() []Middleware {
+	mws := make([]Middleware, 0, len(r.client.middlewares)+len(r.middlewares)+1)
+	mws = append(mws, r.client.middlewares...)
+	mws = append(mws, r.middlewares...)
 
 	checkProxy := r.client.checkProxy
 	if r.checkProxy != nil {
 		checkProxy = r.checkProxy
 	}
-	if checkProxy != nil && !checkProxy(&resp) {
-		r.client.proxySelector.ProxyInvalid(ctx)
+	_, report := r.client.proxySelector.(ProxyReporter)
+	if checkProxy != nil || report {
+		mws = append(mws, CheckProxyMiddleware(checkProxy, r.client.proxySelector))
 	}
 
+	return mws
+}
+
+// roundTrip 通过底层的 http.Client 发送请求
+func (r *Request) roundTrip(req *http.Request) (*Response, error) {
+	var (
+		resp Response
+		err  error
+	)
+	ctx, trace := withEventTrace(req.Context(), req.URL.Host, r.client.hooks)
+	resp.resp, err = r.client.client.Do(req.WithContext(ctx))
+	trace.finish(resp.resp, err)
 	return &resp, err
 }
 
@@ -317,24 +515,20 @@ func (r *Request) Unmarshal(val interface{}) (err error) {
 }
 
 func (r *Request) UnmarshalWithDecrypt(decrypt DecryptFunc, val interface{}) (err error) {
-	_, resp, err := r.String()
+	_, resp, err := r.StringWithDecrypt(decrypt)
 	if err != nil {
 		return err
 	}
-	if decrypt != nil {
-		resp, err = decrypt(resp)
-		if err != nil {
-			return err
-		}
-	}
 	return json.Unmarshal([]byte(resp), val)
 }
 
+// StringWithDecrypt 只对这次的结果解密 不修改请求的中间件 解密失败时也返回状态码
 func (r *Request) StringWithDecrypt(decrypt DecryptFunc) (statusCode int, resp string, err error) {
 	statusCode, resp, err = r.String()
-	if decrypt != nil {
-		resp, err = decrypt(resp)
+	if err != nil || decrypt == nil {
+		return
 	}
+	resp, err = decrypt(resp)
 	return
 }
 
@@ -362,6 +556,34 @@ func (r *Request) Byte() (statusCode int, resp []byte, err error) {
 	return
 }
 
+// clone 复制请求 用于同一个请求多次发送
+func (r *Request) clone() *Request {
+	req := *r
+	req.heads = r.heads.Clone()
+	req.queryParam = cloneValues(r.queryParam)
+	req.queryParamKeys = append([]string(nil), r.queryParamKeys...)
+	req.formData = cloneValues(r.formData)
+	req.cookies = append([]*http.Cookie(nil), r.cookies...)
+	req.retryConditions = append([]RetryCondition(nil), r.retryConditions...)
+	req.middlewares = append([]Middleware(nil), r.middlewares...)
+	req.multipartFields = append([]*multipartField(nil), r.multipartFields...)
+	req.headerOrder = append([]string(nil), r.headerOrder...)
+	req.pseudoHeaderOrder = append([]string(nil), r.pseudoHeaderOrder...)
+	return &req
+}
+
+func cloneValues(values stdurl.Values) stdurl.Values {
+	if values == nil {
+		return nil
+	}
+
+	clone := make(stdurl.Values, len(values))
+	for k, v := range values {
+		clone[k] = append([]string(nil), v...)
+	}
+	return clone
+}
+
 func (r *Request) GetUrl() string {
 	return r.url
 }
@@ -1,20 +1,26 @@
 package httpclient
 
 import (
-	"bytes"
-	"compress/gzip"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	stdurl "net/url"
 	"os"
 )
 
+// ErrBodyConsumed body 已经通过 WriteTo、ToFile 或流式读取读完 不能再读取
+var ErrBodyConsumed = errors.New("httpclient: response body already consumed")
+
 // Response 请求结果
 type Response struct {
 	resp *http.Response
 	body []byte
+	req  *Request // 发送的请求 SSE 重连时使用
+
+	fromCache bool
+	consumed  bool // body 没有缓存到内存就已经读取
 }
 
 // StatusCode 返回状态码
@@ -26,6 +32,11 @@ func (r *Response) StatusCode() int {
 	return r.resp.StatusCode
 }
 
+// FromCache 响应来自 WithCache 的缓存 没有发送请求或者服务端返回了 304
+func (r *Response) FromCache() bool {
+	return r != nil && r.fromCache
+}
+
 // Headers 返回请求结果的heads
 func (r *Response) Headers() http.Header {
 	if r == nil || r.resp == nil {
@@ -63,25 +74,36 @@ func (r *Response) Body() (body []byte, err error) {
 		return r.body, nil
 	}
 
+	if r.consumed {
+		return nil, ErrBodyConsumed
+	}
+
 	if r.resp.Body == nil {
 		return nil, errors.New("response is nil")
 	}
 
 	defer r.resp.Body.Close()
 
-	if r.resp.Header.Get("Content-Encoding") == "gzip" {
-		reader, readerErr := gzip.NewReader(r.resp.Body)
-		if readerErr != nil {
-			return nil, readerErr
-		}
-		r.body, err = io.ReadAll(reader)
-	} else {
-		r.body, err = io.ReadAll(r.resp.Body)
+	reader, err := r.bodyReader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	body, err = io.ReadAll(reader)
+	if err != nil {
+		return nil, err
 	}
+	r.body = body
 
 	return r.body, nil
 }
 
+// bodyReader 按 Content-Encoding 解压body 支持 gzip、br、deflate 和 zstd
+func (r *Response) bodyReader() (io.ReadCloser, error) {
+	return decodeContent(r.resp.Body, r.resp.Header.Get("Content-Encoding"))
+}
+
 // FromJSON 解析请求结果到 v
 func (r *Response) FromJSON(v interface{}) error {
 	resp, err := r.Body()
@@ -92,23 +114,83 @@ func (r *Response) FromJSON(v interface{}) error {
 	return json.Unmarshal(resp, v)
 }
 
-// ToFile 保存请求结果到文件
-func (r *Response) ToFile(filename string) error {
-	f, err := os.Create(filename)
+// Decode 按返回结果的 Content-Type 解码到 v 没有 Content-Type 时按json解码
+func (r *Response) Decode(v interface{}) error {
+	resp, err := r.Body()
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	resp, err := r.Body()
+	contentType := r.Headers().Get("Content-Type")
+	if contentType == "" {
+		return json.Unmarshal(resp, v)
+	}
+
+	codec := GetCodec(contentType)
+	if codec == nil {
+		return fmt.Errorf("httpclient: no codec for content type %q", contentType)
+	}
+
+	return codec.Unmarshal(resp, v)
+}
+
+// ToFile 保存请求结果到文件 body 未读取时以流的方式写入 之后不能再读取 body
+func (r *Response) ToFile(filename string) error {
+	f, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
 
-	_, err = io.Copy(f, bytes.NewReader(resp))
+	_, err = r.WriteTo(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
 	return err
 }
 
+// WriteTo 把body写入 w body 未读取时不会缓存到内存 实现 io.WriterTo
+// 之后不能再读取 body Body 等方法返回 ErrBodyConsumed
+func (r *Response) WriteTo(w io.Writer) (int64, error) {
+	if r == nil || r.resp == nil {
+		return 0, errors.New("response is nil")
+	}
+
+	if r.body != nil {
+		n, err := w.Write(r.body)
+		return int64(n), err
+	}
+
+	if r.consumed {
+		return 0, ErrBodyConsumed
+	}
+
+	if r.resp.Body == nil {
+		return 0, errors.New("response is nil")
+	}
+
+	r.consumed = true
+	defer r.resp.Body.Close()
+
+	reader, err := r.bodyReader()
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	return io.Copy(w, reader)
+}
+
 func (r *Response) Response() *http.Response {
 	return r.resp
 }
+
+// discard 丢弃未读取的body 以便复用链接
+func (r *Response) discard() {
+	if r == nil || r.resp == nil || r.resp.Body == nil || r.body != nil {
+		return
+	}
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(r.resp.Body, 4<<10))
+	r.resp.Body.Close()
+}
@@ -0,0 +1,239 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/windzhu0514/go-utils/delayqueue/backoff"
+)
+
+// RetryCondition 重试条件 resp 和 err 为本次请求的结果 返回 true 时重试
+type RetryCondition func(resp *Response, err error) bool
+
+// DefaultRetryCondition 默认重试条件
+// 网络错误(context 取消或超时除外)、429 和 5xx(501 除外) 时重试
+func DefaultRetryCondition(resp *Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	code := resp.StatusCode()
+	if code == http.StatusTooManyRequests {
+		return true
+	}
+
+	return code == 0 || (code >= 500 && code != http.StatusNotImplemented)
+}
+
+// defaultRetryBackoff 未设置退避策略时使用的策略
+var defaultRetryBackoff = backoff.NewExponentialRandPolicy(100*time.Millisecond, 100*time.Millisecond, 2, 5*time.Second)
+
+func WithRetryCount(count int) ClientOption {
+	return func(client *Client) {
+		client.retryCount = count
+	}
+}
+
+func WithRetryBackoff(policy backoff.Policy) ClientOption {
+	return func(client *Client) {
+		client.retryBackoff = policy
+	}
+}
+
+func WithRetryCondition(conditions ...RetryCondition) ClientOption {
+	return func(client *Client) {
+		client.retryConditions = append(client.retryConditions, conditions...)
+	}
+}
+
+func WithRetryMaxWait(maxWait time.Duration) ClientOption {
+	return func(client *Client) {
+		client.retryMaxWait = maxWait
+	}
+}
+
+// SetRetryCount 设置重试次数 不包括第一次请求
+func (c *Client) SetRetryCount(count int) *Client {
+	c.retryCount = count
+	return c
+}
+
+// SetRetryBackoff 设置重试退避策略
+func (c *Client) SetRetryBackoff(policy backoff.Policy) *Client {
+	c.retryBackoff = policy
+	return c
+}
+
+// AddRetryCondition 添加重试条件 任一条件返回 true 即重试 未设置时使用 DefaultRetryCondition
+func (c *Client) AddRetryCondition(conditions ...RetryCondition) *Client {
+	c.retryConditions = append(c.retryConditions, conditions...)
+	return c
+}
+
+// SetRetryMaxWait 设置单次重试的最长等待时间 同时限制 Retry-After 的等待时间
+func (c *Client) SetRetryMaxWait(maxWait time.Duration) *Client {
+	c.retryMaxWait = maxWait
+	return c
+}
+
+// SetRetryCount 设置该请求的重试次数 覆盖 client 的设置 小于0时不重试
+func (r *Request) SetRetryCount(count int) *Request {
+	r.retryCount = count
+	return r
+}
+
+// SetRetryBackoff 设置该请求的重试退避策略
+func (r *Request) SetRetryBackoff(policy backoff.Policy) *Request {
+	r.retryBackoff = policy
+	return r
+}
+
+// AddRetryCondition 添加该请求的重试条件 与 client 的重试条件同时生效
+func (r *Request) AddRetryCondition(conditions ...RetryCondition) *Request {
+	r.retryConditions = append(r.retryConditions, conditions...)
+	return r
+}
+
+func (r *Request) getRetryCount() int {
+	count := r.client.retryCount
+	if r.retryCount != 0 {
+		count = r.retryCount
+	}
+
+	if count < 0 {
+		return 0
+	}
+
+	return count
+}
+
+func (r *Request) shouldRetry(resp *Response, err error) bool {
+	conditions := make([]RetryCondition, 0, len(r.client.retryConditions)+len(r.retryConditions))
+	conditions = append(conditions, r.client.retryConditions...)
+	conditions = append(conditions, r.retryConditions...)
+	if len(conditions) == 0 {
+		return DefaultRetryCondition(resp, err)
+	}
+
+	for _, condition := range conditions {
+		if condition(resp, err) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retryWait 计算第 attempts 次重试前的等待时间 优先使用响应的 Retry-After
+func (r *Request) retryWait(attempts int, resp *Response) time.Duration {
+	policy := r.client.retryBackoff
+	if r.retryBackoff != nil {
+		policy = r.retryBackoff
+	}
+	if policy == nil {
+		policy = defaultRetryBackoff
+	}
+
+	wait := policy.BackOff(attempts)
+	if after, ok := parseRetryAfter(resp); ok {
+		wait = after
+	}
+
+	if r.client.retryMaxWait > 0 && wait > r.client.retryMaxWait {
+		wait = r.client.retryMaxWait
+	}
+
+	return wait
+}
+
+// parseRetryAfter 解析 Retry-After 支持秒数和 HTTP-date 两种格式
+func parseRetryAfter(resp *Response) (time.Duration, bool) {
+	if resp == nil || resp.resp == nil {
+		return 0, false
+	}
+
+	value := resp.resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	wait := time.Until(t)
+	if wait < 0 {
+		wait = 0
+	}
+
+	return wait, true
+}
+
+// sleepContext 等待 d 或者 ctx 结束
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// bodyGetter 每次调用返回一个从头开始读取的请求 body
+type bodyGetter func() (io.Reader, error)
+
+// lengthReader 长度已知的 body 发送时设置 Content-Length 不使用 chunked
+type lengthReader struct {
+	io.Reader
+	length int64
+}
+
+// rewindBody 让 io.Reader 可以重复读取
+// io.ReadSeeker 通过 Seek 回到初始位置，其他 io.Reader 一次性读取到内存
+func rewindBody(body io.Reader) (bodyGetter, error) {
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		var end int64
+		if err == nil {
+			end, err = seeker.Seek(0, io.SeekEnd)
+		}
+		if err == nil {
+			return func() (io.Reader, error) {
+				if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+					return nil, err
+				}
+				// 隐藏 Close 方法，避免 Transport 读取后关闭
+				return &lengthReader{Reader: seeker, length: end - offset}, nil
+			}, nil
+		}
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() (io.Reader, error) {
+		return bytes.NewReader(data), nil
+	}, nil
+}
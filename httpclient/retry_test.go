@@ -0,0 +1,185 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/windzhu0514/go-utils/delayqueue/backoff"
+)
+
+func TestRequest_Retry(t *testing.T) {
+	var attempts int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "hello" {
+			t.Errorf("attempt %d body = %q, want hello", atomic.LoadInt32(&attempts)+1, body)
+		}
+
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("OK"))
+	}))
+	defer s.Close()
+
+	client := NewClient(WithRetryCount(3), WithRetryBackoff(backoff.NewFixedPolicy(time.Millisecond, time.Millisecond)))
+	// io.MultiReader 不能 Seek，只能读取一次
+	req := client.NewRequest(http.MethodPost, s.URL).SetBody(MIMEPlain, io.MultiReader(strings.NewReader("hello")))
+	code, resp, err := req.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusOK || resp != "OK" {
+		t.Fatalf("got %d %q, want 200 OK", code, resp)
+	}
+
+	if attempts != 3 {
+		t.Fatalf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestRequest_RetryExhausted(t *testing.T) {
+	var attempts int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer s.Close()
+
+	client := NewClient(WithRetryBackoff(backoff.NewFixedPolicy(0, 0)))
+	req := client.NewRequest(http.MethodGet, s.URL).SetRetryCount(2)
+	resp, err := req.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode() != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502", resp.StatusCode())
+	}
+
+	if attempts != 3 {
+		t.Fatalf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestRequest_RetryCondition(t *testing.T) {
+	var attempts int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			_, _ = w.Write([]byte("busy"))
+			return
+		}
+		_, _ = w.Write([]byte("OK"))
+	}))
+	defer s.Close()
+
+	client := NewClient(WithRetryCount(1), WithRetryBackoff(backoff.NewFixedPolicy(0, 0)))
+	req := client.NewRequest(http.MethodGet, s.URL).AddRetryCondition(func(resp *Response, err error) bool {
+		body, _ := resp.Body()
+		return string(body) == "busy"
+	})
+	_, resp, err := req.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp != "OK" {
+		t.Fatalf("resp = %q, want OK", resp)
+	}
+}
+
+func TestRequest_RetryAfter(t *testing.T) {
+	var attempts int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte("OK"))
+	}))
+	defer s.Close()
+
+	client := NewClient(WithRetryCount(1), WithRetryBackoff(backoff.NewFixedPolicy(0, 0)), WithRetryMaxWait(50*time.Millisecond))
+	start := time.Now()
+	code, _, err := client.NewRequest(http.MethodGet, s.URL).String()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+
+	if cost := time.Since(start); cost < 50*time.Millisecond || cost > time.Second {
+		t.Fatalf("wait %s, want Retry-After limited by max wait", cost)
+	}
+}
+
+func TestRequest_RetryContextCancel(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	client := NewClient(WithRetryCount(10), WithRetryBackoff(backoff.NewFixedPolicy(time.Second, time.Second)))
+	resp, err := client.NewRequestWithContext(ctx, http.MethodGet, s.URL).Do()
+	if err != context.DeadlineExceeded || resp != nil {
+		t.Fatalf("resp = %v, err = %v, want context.DeadlineExceeded", resp, err)
+	}
+}
+
+func TestRequest_RetryContentLength(t *testing.T) {
+	var attempts int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.ContentLength != 5 || len(r.TransferEncoding) > 0 || string(body) != "hello" {
+			t.Errorf("content length = %d, transfer encoding = %v, body = %q", r.ContentLength, r.TransferEncoding, body)
+		}
+		if atomic.AddInt32(&attempts, 1) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer s.Close()
+
+	client := NewClient(WithRetryCount(1), WithRetryBackoff(backoff.NewFixedPolicy(time.Millisecond, time.Millisecond)))
+	reader := strings.NewReader("--hello")
+	_, _ = reader.Seek(2, io.SeekStart)
+	resp, err := client.NewRequest(http.MethodPost, s.URL).SetBody(MIMEPlain, reader).Do()
+	if err != nil || resp.StatusCode() != http.StatusOK || atomic.LoadInt32(&attempts) != 2 {
+		t.Fatalf("status = %d, attempts = %d, err = %v", resp.StatusCode(), attempts, err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0, true},
+		{"abc", 0, false},
+	}
+	for _, tt := range tests {
+		resp := &Response{resp: &http.Response{Header: http.Header{}}}
+		resp.resp.Header.Set("Retry-After", tt.value)
+		got, ok := parseRetryAfter(resp)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %s, %v, want %s, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
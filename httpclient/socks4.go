@@ -0,0 +1,138 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// socks4Dialer 通过 SOCKS4 代理建立连接 remoteDNS 为 true 时使用 SOCKS4a 由代理解析域名
+type socks4Dialer struct {
+	proxyAddr string
+	userID    string
+	remoteDNS bool
+	forward   *net.Dialer
+	resolver  *dnsResolver // 本地解析域名 nil 使用系统解析
+}
+
+func (d *socks4Dialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *socks4Dialer) DialContext(ctx context.Context, network, addr string) (conn net.Conn, err error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("socks4: invalid port %q", portStr)
+	}
+
+	var hostname string
+	ip := net.ParseIP(host)
+	if ip == nil && !d.remoteDNS {
+		ips, err := d.resolver.lookupIP(ctx, host, portStr)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range ips {
+			if addr.To4() != nil {
+				ip = addr
+				break
+			}
+		}
+		if ip == nil {
+			return nil, fmt.Errorf("socks4: no IPv4 address for %s", host)
+		}
+	}
+	if ip == nil {
+		// SOCKS4a 使用 0.0.0.x 表示后面跟着域名
+		ip, hostname = net.IPv4(0, 0, 0, 1), host
+	}
+	if ip = ip.To4(); ip == nil {
+		return nil, errors.New("socks4: IPv6 address is not supported")
+	}
+
+	req := []byte{4, 1, byte(port >> 8), byte(port)}
+	req = append(req, ip...)
+	req = append(req, d.userID...)
+	req = append(req, 0)
+	if hostname != "" {
+		req = append(req, hostname...)
+		req = append(req, 0)
+	}
+
+	conn, err = d.forward.DialContext(ctx, "tcp", d.proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// ctx 取消时中断握手
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer func() {
+		if !stop() && err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			conn.Close()
+			conn = nil
+		}
+	}()
+
+	if _, err = conn.Write(req); err != nil {
+		return conn, err
+	}
+	resp := make([]byte, 8)
+	if _, err = io.ReadFull(conn, resp); err != nil {
+		return conn, err
+	}
+	if resp[1] != 0x5a {
+		return conn, fmt.Errorf("socks4: request rejected, code %#x", resp[1])
+	}
+	return conn, nil
+}
+
+// localDNSDialer 在本地解析域名后交给 socks5 代理
+type localDNSDialer struct {
+	forward  proxy.ContextDialer
+	resolver *dnsResolver // nil 使用系统解析
+}
+
+func (d *localDNSDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *localDNSDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) == nil {
+		ips, err := d.resolver.lookupIP(ctx, host, port)
+		if err != nil {
+			return nil, err
+		}
+		if len(ips) == 0 {
+			return nil, &net.DNSError{Err: dnsNotFoundErrMessage, Name: host, IsNotFound: true}
+		}
+		// 优先使用 IPv4
+		ip := ips[0]
+		for _, addr := range ips {
+			if addr.To4() != nil {
+				ip = addr
+				break
+			}
+		}
+		addr = net.JoinHostPort(ip.String(), port)
+	}
+	return d.forward.DialContext(ctx, network, addr)
+}
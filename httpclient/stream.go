@@ -0,0 +1,323 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultSSERetryDelay = 3 * time.Second
+
+// SSEEvent 一个 Server-Sent Event
+type SSEEvent struct {
+	ID    string        // 最近一次收到的 id 重连时作为 Last-Event-ID 发送
+	Event string        // 事件类型 默认为 message
+	Data  string        // 多行 data 以 \n 连接
+	Retry time.Duration // 事件中的 retry 没有时为 0
+}
+
+type sseConfig struct {
+	maxReconnects int
+	retryDelay    time.Duration
+}
+
+type SSEOption func(*sseConfig)
+
+// WithSSEReconnect 连接断开后自动重连 重连时发送 Last-Event-ID
+// maxReconnects 为连续重连的最大次数 收到事件后重新计数 <0 不限制
+func WithSSEReconnect(maxReconnects int) SSEOption {
+	return func(cfg *sseConfig) {
+		cfg.maxReconnects = maxReconnects
+	}
+}
+
+// WithSSERetryDelay 重连前的等待时间 默认3秒 服务端的 retry 字段会覆盖这个值
+func WithSSERetryDelay(delay time.Duration) SSEOption {
+	return func(cfg *sseConfig) {
+		cfg.retryDelay = delay
+	}
+}
+
+// SSE 以 text/event-stream 格式逐个读取事件 不会把 body 读取到内存
+// 迭代器按需读取响应 停止迭代或 ctx 取消时关闭响应
+// 重连时使用原请求的 context 发送 状态码为 204 时停止重连
+func (r *Response) SSE(ctx context.Context, opts ...SSEOption) iter.Seq2[*SSEEvent, error] {
+	cfg := sseConfig{retryDelay: defaultSSERetryDelay}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(yield func(*SSEEvent, error) bool) {
+		var (
+			resp       = r
+			state      = sseState{retry: cfg.retryDelay}
+			reconnects = 0
+		)
+		// 重连的请求保留原来的 context ctx 取消时也取消
+		var reqCtx context.Context
+		if r.req != nil {
+			parent := r.req.Context()
+			if parent == nil {
+				parent = context.Background()
+			}
+			var cancel context.CancelCauseFunc
+			reqCtx, cancel = context.WithCancelCause(parent)
+			defer cancel(nil)
+			stop := context.AfterFunc(ctx, func() {
+				cancel(context.Cause(ctx))
+			})
+			defer stop()
+		}
+
+		for {
+			if err := checkStreamResponse(resp); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			received := false
+			err := resp.readStream(ctx, func(reader *bufio.Reader) error {
+				return state.read(reader, func(event *SSEEvent) bool {
+					received = true
+					return yield(event, nil)
+				})
+			})
+			if errors.Is(err, errStopStream) {
+				return
+			}
+			if ctx.Err() != nil {
+				yield(nil, ctx.Err())
+				return
+			}
+			if err == io.EOF {
+				err = nil
+			}
+			if received {
+				reconnects = 0
+			}
+
+			for {
+				if r.req == nil || cfg.maxReconnects >= 0 && reconnects >= cfg.maxReconnects {
+					if err != nil {
+						yield(nil, err)
+					}
+					return
+				}
+				reconnects++
+
+				if sleepErr := sleepContext(ctx, state.retry); sleepErr != nil {
+					yield(nil, sleepErr)
+					return
+				}
+				req := r.req.clone().WithContext(reqCtx)
+				if state.lastID != "" {
+					req.SetHead("Last-Event-ID", state.lastID)
+				}
+				resp, err = req.Do()
+				if err == nil {
+					break
+				}
+			}
+			if resp.StatusCode() == http.StatusNoContent {
+				resp.discard()
+				return
+			}
+		}
+	}
+}
+
+// SSEChan 同 SSE 以 channel 的方式返回事件 channel 无缓冲 读取事件后才继续读取响应
+// 事件 channel 关闭后可以从错误 channel 读取错误 不再读取时需要取消 ctx
+func (r *Response) SSEChan(ctx context.Context, opts ...SSEOption) (<-chan *SSEEvent, <-chan error) {
+	return seqChan(ctx, r.SSE(ctx, opts...))
+}
+
+// NDJSON 逐行读取 newline-delimited JSON 跳过空行
+func (r *Response) NDJSON(ctx context.Context) iter.Seq2[json.RawMessage, error] {
+	return func(yield func(json.RawMessage, error) bool) {
+		if err := checkStreamResponse(r); err != nil {
+			yield(nil, err)
+			return
+		}
+
+		err := r.readStream(ctx, func(reader *bufio.Reader) error {
+			for {
+				line, err := reader.ReadBytes('\n')
+				if line = bytes.TrimSpace(line); len(line) > 0 {
+					if !yield(json.RawMessage(line), nil) {
+						return errStopStream
+					}
+				}
+				if err != nil {
+					return err
+				}
+			}
+		})
+		if errors.Is(err, errStopStream) {
+			return
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		if err != nil && err != io.EOF {
+			yield(nil, err)
+		}
+	}
+}
+
+// NDJSONChan 同 NDJSON 以 channel 的方式返回
+func (r *Response) NDJSONChan(ctx context.Context) (<-chan json.RawMessage, <-chan error) {
+	return seqChan(ctx, r.NDJSON(ctx))
+}
+
+// DecodeNDJSON 逐行读取 newline-delimited JSON 并解码为 T 解码失败时返回错误 可以继续迭代
+func DecodeNDJSON[T any](ctx context.Context, r *Response) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for line, err := range r.NDJSON(ctx) {
+			var v T
+			if err == nil {
+				err = json.Unmarshal(line, &v)
+			}
+			if !yield(v, err) {
+				return
+			}
+		}
+	}
+}
+
+var errStopStream = errors.New("httpclient: stream stopped")
+
+func checkStreamResponse(r *Response) error {
+	if r == nil || r.resp == nil {
+		return errors.New("response is nil")
+	}
+	if r.resp.StatusCode < 200 || r.resp.StatusCode > 299 {
+		r.discard()
+		return fmt.Errorf("httpclient: unexpected status code %d", r.resp.StatusCode)
+	}
+	return nil
+}
+
+// readStream 按 Content-Encoding 解压后读取 body ctx 取消时关闭 body 结束阻塞的读取
+func (r *Response) readStream(ctx context.Context, read func(*bufio.Reader) error) error {
+	if r.body != nil {
+		return read(bufio.NewReader(bytes.NewReader(r.body)))
+	}
+	if r.consumed {
+		return ErrBodyConsumed
+	}
+	if r.resp.Body == nil {
+		return errors.New("response is nil")
+	}
+	r.consumed = true
+	defer r.resp.Body.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		r.resp.Body.Close()
+	})
+	defer stop()
+
+	reader, err := r.bodyReader()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return read(bufio.NewReader(reader))
+}
+
+// sseState 跨连接保留的状态
+type sseState struct {
+	lastID string
+	retry  time.Duration
+}
+
+// read 按 https://html.spec.whatwg.org/multipage/server-sent-events.html 解析事件
+// 连接结束时不完整的事件被丢弃
+func (s *sseState) read(reader *bufio.Reader, yield func(*SSEEvent) bool) error {
+	var (
+		data    strings.Builder
+		hasData bool
+		event   SSEEvent
+	)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			if hasData {
+				event.ID = s.lastID
+				event.Data = strings.TrimSuffix(data.String(), "\n")
+				if event.Event == "" {
+					event.Event = "message"
+				}
+				e := event
+				if !yield(&e) {
+					return errStopStream
+				}
+			}
+			data.Reset()
+			hasData = false
+			event = SSEEvent{}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				s.lastID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				event.Retry = time.Duration(ms) * time.Millisecond
+				s.retry = event.Retry
+			}
+		}
+	}
+}
+
+// seqChan 把迭代器转换为 channel 遇到错误时停止
+func seqChan[T any](ctx context.Context, seq iter.Seq2[T, error]) (<-chan T, <-chan error) {
+	ch := make(chan T)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(ch)
+		for v, err := range seq {
+			if err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case ch <- v:
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return ch, errc
+}
@@ -0,0 +1,189 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResponse_SSE(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": comment\n\n")
+		fmt.Fprint(w, "data: first\r\n\r\n")
+		fmt.Fprint(w, "event: update\nid: 7\nretry: 1500\ndata:line1\ndata: line2\n\n")
+		fmt.Fprint(w, "id: 8\n\n")
+		fmt.Fprint(w, "data\n\n")
+		fmt.Fprint(w, "data: incomplete")
+	}))
+	defer s.Close()
+
+	resp, err := NewRequest(http.MethodGet, s.URL).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []SSEEvent
+	for event, err := range resp.SSE(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, *event)
+	}
+	want := []SSEEvent{
+		{Event: "message", Data: "first"},
+		{ID: "7", Event: "update", Data: "line1\nline2", Retry: 1500 * time.Millisecond},
+		{ID: "8", Event: "message", Data: ""},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %+v, want %+v", events, want)
+	}
+}
+
+func TestResponse_SSEReconnect(t *testing.T) {
+	var requests atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.Header.Get("Last-Event-ID") {
+		case "":
+			fmt.Fprint(w, "retry: 1\nid: 1\ndata: a\n\n")
+		case "1":
+			fmt.Fprint(w, "id: 2\ndata: b\n\n")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer s.Close()
+
+	resp, err := NewRequest(http.MethodGet, s.URL).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data []string
+	for event, err := range resp.SSE(context.Background(), WithSSEReconnect(-1)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, event.Data)
+	}
+	if !reflect.DeepEqual(data, []string{"a", "b"}) || requests.Load() != 3 {
+		t.Fatalf("data = %v, requests = %d", data, requests.Load())
+	}
+
+	// 重连失败的次数超过限制
+	resp, _ = NewRequest(http.MethodGet, s.URL).Do()
+	s.Close()
+	var last error
+	for _, err := range resp.SSE(context.Background(), WithSSEReconnect(2), WithSSERetryDelay(time.Millisecond)) {
+		last = err
+	}
+	if last == nil {
+		t.Fatal("reconnect to closed server should fail")
+	}
+}
+
+func TestResponse_SSEChanCancel(t *testing.T) {
+	done := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: a\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer s.Close()
+	defer close(done)
+
+	resp, err := NewRequest(http.MethodGet, s.URL).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errc := resp.SSEChan(ctx, WithSSEReconnect(-1))
+	if event := <-events; event.Data != "a" {
+		t.Fatalf("event = %+v", event)
+	}
+	cancel()
+	for range events {
+	}
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context canceled", err)
+	}
+}
+
+func TestResponse_SSEReconnectCancel(t *testing.T) {
+	var requests atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) > 1 {
+			// 重连的请求一直不响应
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprint(w, "retry: 1\ndata: a\n\n")
+	}))
+	defer s.Close()
+
+	resp, err := NewRequest(http.MethodGet, s.URL).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	var last error
+	for _, err := range resp.SSE(ctx, WithSSEReconnect(-1)) {
+		last = err
+	}
+	if !errors.Is(last, context.DeadlineExceeded) || requests.Load() < 2 {
+		t.Fatalf("err = %v, requests = %d", last, requests.Load())
+	}
+}
+
+func TestResponse_NDJSON(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprint(w, "{\"n\":1}\n\n{\"n\":2}\r\nbad\n{\"n\":3}")
+	}))
+	defer s.Close()
+
+	resp, err := NewRequest(http.MethodGet, s.URL).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	type item struct{ N int }
+	var got []int
+	var decodeErrs int
+	for v, err := range DecodeNDJSON[item](context.Background(), resp) {
+		if err != nil {
+			decodeErrs++
+			continue
+		}
+		got = append(got, v.N)
+	}
+	if !reflect.DeepEqual(got, []int{1, 2, 3}) || decodeErrs != 1 {
+		t.Fatalf("got %v, %d errors", got, decodeErrs)
+	}
+
+	resp, _ = NewRequest(http.MethodGet, s.URL).Do()
+	lines, errc := resp.NDJSONChan(context.Background())
+	var raw []string
+	for line := range lines {
+		raw = append(raw, string(line))
+	}
+	if err := <-errc; err != nil || !reflect.DeepEqual(raw, []string{`{"n":1}`, `{"n":2}`, "bad", `{"n":3}`}) {
+		t.Fatalf("lines = %v, err = %v", raw, err)
+	}
+
+	resp, _ = NewRequest(http.MethodGet, s.URL).Do()
+	resp.resp.StatusCode = http.StatusInternalServerError
+	for _, err := range resp.NDJSON(context.Background()) {
+		if err == nil {
+			t.Fatal("non 2xx response should fail")
+		}
+	}
+}
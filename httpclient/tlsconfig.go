@@ -0,0 +1,166 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+// ErrCertificatePinMismatch 服务端证书链中没有匹配固定的公钥
+var ErrCertificatePinMismatch = errors.New("httpclient: certificate pin mismatch")
+
+// 共享的tls会话缓存 再次连接时恢复会话 nil 使用容量为64的 LRU 缓存
+// TLS 1.3 通过 pre_shared_key 扩展恢复 指纹没有这个扩展时加在最后 没有会话时不发送
+// 随机指纹不恢复会话
+func WithTlsConnOptSessionCache(cache tls.ClientSessionCache) TlsConnOption {
+	return func(tr *Transport) {
+		if cache == nil {
+			cache = tls.NewLRUClientSessionCache(0)
+		}
+		tr.tlsConfig.ClientSessionCache = cache
+		tr.tlsConfig.OmitEmptyPsk = true
+	}
+}
+
+// 校验服务端证书使用的根证书 nil 使用系统根证书
+func WithTlsConnOptRootCAs(pool *x509.CertPool) TlsConnOption {
+	return func(tr *Transport) {
+		tr.tlsConfig.RootCAs = pool
+	}
+}
+
+// 双向认证的客户端证书
+func WithTlsConnOptClientCertificates(certs ...tls.Certificate) TlsConnOption {
+	return func(tr *Transport) {
+		tr.tlsConfig.Certificates = append(tr.tlsConfig.Certificates, certs...)
+	}
+}
+
+// 从PEM文件加载双向认证的客户端证书 加载失败时请求返回错误
+func WithTlsConnOptClientCertFile(certFile, keyFile string) TlsConnOption {
+	return func(tr *Transport) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			tr.specErr = fmt.Errorf("httpclient: load client certificate: %w", err)
+			return
+		}
+		tr.tlsConfig.Certificates = append(tr.tlsConfig.Certificates, cert)
+	}
+}
+
+// 不校验服务端证书 固定的公钥仍然会校验
+func WithTlsConnOptInsecureSkipVerify(skip bool) TlsConnOption {
+	return func(tr *Transport) {
+		tr.tlsConfig.InsecureSkipVerify = skip
+	}
+}
+
+// 固定host的公钥 pins 为证书 SPKI 的 sha256 的 base64 可以带 sha256/ 前缀
+// 证书链中任意一个证书匹配即可 可以多次调用设置不同的host
+func WithTlsConnOptPinnedKeys(host string, pins ...string) TlsConnOption {
+	return func(tr *Transport) {
+		if tr.pins == nil {
+			tr.pins = make(map[string][]string)
+		}
+		host = strings.ToLower(host)
+		for _, pin := range pins {
+			tr.pins[host] = append(tr.pins[host], strings.TrimPrefix(pin, "sha256/"))
+		}
+	}
+}
+
+// SPKIHash 计算证书公钥的 sha256 用于 WithTlsConnOptPinnedKeys
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// tlsConfigFor 复制配置模板 设置 ServerName 和公钥校验
+func (t *Transport) tlsConfigFor(serverName string) *tls.Config {
+	cfg := t.tlsConfig.Clone()
+	cfg.ServerName = serverName
+	if pins := t.pins[strings.ToLower(serverName)]; len(pins) > 0 {
+		cfg.VerifyPeerCertificate = verifyPinnedKeys(pins)
+	}
+	return cfg
+}
+
+// clientHello 返回握手使用的 ClientHelloID 和需要设置的 ClientHelloSpec spec 为 nil 时直接使用 ClientHelloID
+// 使用会话缓存时 预设的指纹也转成 spec 保证有 pre_shared_key 扩展 否则 utls 恢复 TLS 1.3 会话时 panic
+func (t *Transport) clientHello(cfg *tls.Config) (tls.ClientHelloID, *tls.ClientHelloSpec, error) {
+	if t.ClientHelloID == tls.HelloCustom {
+		spec := new(tls.ClientHelloSpec)
+		if err := t.deepCopyClientHelloSpec(spec, t.ClientHelloSpec); err != nil {
+			return t.ClientHelloID, nil, err
+		}
+		if cfg.ClientSessionCache != nil {
+			addPskExtension(spec)
+		}
+		return tls.HelloCustom, spec, nil
+	}
+
+	if cfg.ClientSessionCache == nil || t.ClientHelloID == tls.HelloGolang {
+		return t.ClientHelloID, nil, nil
+	}
+
+	spec, err := tls.UTLSIdToSpec(t.ClientHelloID)
+	if err != nil {
+		// 随机指纹每次生成 不能加扩展
+		cfg.ClientSessionCache = nil
+		return t.ClientHelloID, nil, nil
+	}
+	addPskExtension(&spec)
+	return tls.HelloCustom, &spec, nil
+}
+
+// addPskExtension 支持 TLS 1.3 的 spec 没有 pre_shared_key 扩展时加在最后
+func addPskExtension(spec *tls.ClientHelloSpec) {
+	tls13 := spec.TLSVersMax >= tls.VersionTLS13
+	for _, ext := range spec.Extensions {
+		switch e := ext.(type) {
+		case tls.PreSharedKeyExtension:
+			return
+		case *tls.SupportedVersionsExtension:
+			for _, v := range e.Versions {
+				tls13 = tls13 || v == tls.VersionTLS13
+			}
+		}
+	}
+	if tls13 {
+		spec.Extensions = append(spec.Extensions, &tls.UtlsPreSharedKeyExtension{OmitEmptyPsk: true})
+	}
+}
+
+func verifyPinnedKeys(pins []string) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		var certs []*x509.Certificate
+		for _, chain := range verifiedChains {
+			certs = append(certs, chain...)
+		}
+		if len(verifiedChains) == 0 {
+			// InsecureSkipVerify 时没有验证过的证书链 只校验服务端发送的证书
+			for _, raw := range rawCerts {
+				cert, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return err
+				}
+				certs = append(certs, cert)
+			}
+		}
+
+		for _, cert := range certs {
+			hash := SPKIHash(cert)
+			for _, pin := range pins {
+				if hash == pin {
+					return nil
+				}
+			}
+		}
+		return ErrCertificatePinMismatch
+	}
+}
@@ -0,0 +1,166 @@
+package httpclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	stdtls "crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tls "github.com/refraction-networking/utls"
+)
+
+func tlsGet(t *testing.T, tr *Transport, url string) (string, error) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestTlsConfig_Verify(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer s.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(s.Certificate())
+	pin := SPKIHash(s.Certificate())
+
+	tests := []struct {
+		name    string
+		opts    []TlsConnOption
+		wantErr error
+	}{
+		{"untrusted", nil, &x509.UnknownAuthorityError{}},
+		{"root ca", []TlsConnOption{WithTlsConnOptRootCAs(pool)}, nil},
+		{"insecure", []TlsConnOption{WithTlsConnOptInsecureSkipVerify(true)}, nil},
+		{"pin", []TlsConnOption{WithTlsConnOptRootCAs(pool), WithTlsConnOptPinnedKeys("127.0.0.1", "sha256/"+pin)}, nil},
+		{"pin mismatch", []TlsConnOption{WithTlsConnOptRootCAs(pool), WithTlsConnOptPinnedKeys("127.0.0.1", "AAAA")}, ErrCertificatePinMismatch},
+		{"insecure pin mismatch", []TlsConnOption{WithTlsConnOptInsecureSkipVerify(true), WithTlsConnOptPinnedKeys("127.0.0.1", "AAAA")}, ErrCertificatePinMismatch},
+		{"other host pin", []TlsConnOption{WithTlsConnOptRootCAs(pool), WithTlsConnOptPinnedKeys("example.com", "AAAA")}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := tlsGet(t, NewTransport(tt.opts...), s.URL)
+			switch want := tt.wantErr.(type) {
+			case nil:
+				if err != nil || body != "ok" {
+					t.Fatalf("body = %q, err = %v", body, err)
+				}
+			case *x509.UnknownAuthorityError:
+				if !errors.As(err, want) {
+					t.Fatalf("err = %v, want %T", err, want)
+				}
+			default:
+				if !errors.Is(err, want) {
+					t.Fatalf("err = %v, want %v", err, want)
+				}
+			}
+		})
+	}
+}
+
+func newClientCertificate(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "httpclient"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, cert
+}
+
+func TestTlsConfig_ClientCertificate(t *testing.T) {
+	clientCert, x509Cert := newClientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(x509Cert)
+
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	s.TLS = &stdtls.Config{ClientAuth: stdtls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	s.StartTLS()
+	defer s.Close()
+
+	body, err := tlsGet(t, NewTransport(WithTlsConnOptInsecureSkipVerify(true), WithTlsConnOptClientCertificates(clientCert)), s.URL)
+	if err != nil || body != "httpclient" {
+		t.Fatalf("body = %q, err = %v", body, err)
+	}
+
+	if _, err = tlsGet(t, NewTransport(WithTlsConnOptInsecureSkipVerify(true)), s.URL); err == nil {
+		t.Fatal("request without client certificate should fail")
+	}
+
+	tr := NewTransport(WithTlsConnOptClientCertFile("not-exist.pem", "not-exist.key"))
+	if _, err = tlsGet(t, tr, s.URL); err == nil || !strings.Contains(err.Error(), "client certificate") {
+		t.Fatalf("err = %v, want load client certificate error", err)
+	}
+}
+
+func TestTlsConfig_SessionCache(t *testing.T) {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS.DidResume {
+			_, _ = w.Write([]byte("resumed"))
+		}
+	}))
+	s.TLS = &stdtls.Config{MaxVersion: stdtls.VersionTLS12}
+	s.StartTLS()
+	defer s.Close()
+
+	tr := NewTransport(WithTlsConnOptInsecureSkipVerify(true), WithTlsConnOptSessionCache(nil))
+	for i, want := range []string{"", "resumed"} {
+		body, err := tlsGet(t, tr, s.URL)
+		if err != nil || body != want {
+			t.Fatalf("request %d: body = %q, err = %v, want %q", i, body, err, want)
+		}
+		// 关闭连接 下次请求重新握手
+		tr.CloseIdleConnections()
+	}
+}
+
+func TestTlsConfig_SessionCacheTLS13(t *testing.T) {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS.DidResume {
+			_, _ = w.Write([]byte("resumed"))
+		}
+	}))
+	s.EnableHTTP2 = true
+	s.StartTLS()
+	defer s.Close()
+
+	for _, id := range []tls.ClientHelloID{ProfileChrome.ClientHelloID, ProfileFirefox.ClientHelloID, tls.HelloChrome_100_PSK} {
+		tr := NewTransport(WithTlsConnOptInsecureSkipVerify(true), WithTlsConnOptClientHelloID(id), WithTlsConnOptSessionCache(nil))
+		for i, want := range []string{"", "resumed"} {
+			body, err := tlsGet(t, tr, s.URL)
+			if err != nil || body != want {
+				t.Fatalf("%s request %d: body = %q, err = %v, want %q", id.Str(), i, body, err, want)
+			}
+			tr.CloseIdleConnections()
+		}
+	}
+}
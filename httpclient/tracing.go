@@ -0,0 +1,126 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/windzhu0514/go-utils/httpclient/metadata"
+)
+
+const tracerName = "github.com/windzhu0514/go-utils/httpclient"
+
+// tracing OpenTelemetry 配置 为 nil 时不创建 span 也不注入请求头
+type tracing struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// newTracing tp 为 nil 使用 otel.GetTracerProvider() propagator 为 nil 使用 W3C traceparent
+func newTracing(tp trace.TracerProvider, propagator propagation.TextMapPropagator) *tracing {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	if propagator == nil {
+		propagator = propagation.TraceContext{}
+	}
+	return &tracing{
+		tracer:     tp.Tracer(tracerName),
+		propagator: propagator,
+	}
+}
+
+// WithTracing 为每次 Do 创建 OpenTelemetry 客户端 span 并注入 traceparent 请求头
+// tp 为 nil 使用全局的 TracerProvider propagator 为 nil 使用 W3C traceparent
+func WithTracing(tp trace.TracerProvider, propagator propagation.TextMapPropagator) ClientOption {
+	return func(client *Client) {
+		client.tracing = newTracing(tp, propagator)
+	}
+}
+
+// 为每次 RoundTrip 创建 OpenTelemetry 客户端 span 并注入 traceparent 请求头 参数同 WithTracing
+func WithTlsConnOptTracing(tp trace.TracerProvider, propagator propagation.TextMapPropagator) TlsConnOption {
+	return func(tr *Transport) {
+		tr.tracing = newTracing(tp, propagator)
+	}
+}
+
+// WithMetadataHeaders 把 metadata 中的值作为请求头发送 rules 的 key 为 metadata 的 key value 为请求头
+// 请求已经设置的请求头不会被覆盖
+func WithMetadataHeaders(rules map[string]string) ClientOption {
+	return func(client *Client) {
+		if client.metadataHeaders == nil {
+			client.metadataHeaders = make(map[string]string, len(rules))
+		}
+		for key, header := range rules {
+			client.metadataHeaders[key] = header
+		}
+	}
+}
+
+// setMetadataHeaders 按规则把 context 中的 metadata 写入请求头
+func setMetadataHeaders(ctx context.Context, header http.Header, rules map[string]string) {
+	if len(rules) == 0 {
+		return
+	}
+	md, ok := metadata.FromRequestContext(ctx)
+	if !ok {
+		return
+	}
+	for key, name := range rules {
+		if value := md.Get(key); value != "" && header.Get(name) == "" {
+			header.Set(name, value)
+		}
+	}
+}
+
+// start 创建客户端 span 未开启时返回 nil
+func (t *tracing) start(ctx context.Context, method string, u *url.URL) (context.Context, trace.Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(method),
+		semconv.URLFull(u.Redacted()),
+		semconv.ServerAddress(u.Hostname()),
+	}
+	if port, err := strconv.Atoi(u.Port()); err == nil {
+		attrs = append(attrs, semconv.ServerPort(port))
+	}
+	return t.tracer.Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// inject 把 context 中的 span 写入请求头
+func (t *tracing) inject(ctx context.Context, header http.Header) {
+	if t == nil {
+		return
+	}
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// endSpan 记录响应状态码和错误 状态码 >= 400 标记为错误
+func endSpan(span trace.Span, resp *http.Response, err error) {
+	if span == nil {
+		return
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else if resp != nil {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+		if resp.StatusCode >= http.StatusBadRequest {
+			span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+		}
+	}
+	span.End()
+}
@@ -0,0 +1,196 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/windzhu0514/go-utils/httpclient/metadata"
+)
+
+// testTracerProvider 记录结束的 span
+type testTracerProvider struct {
+	embedded.TracerProvider
+
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+func (p *testTracerProvider) Tracer(name string, options ...trace.TracerOption) trace.Tracer {
+	return &testTracer{provider: p}
+}
+
+func (p *testTracerProvider) ended() []*testSpan {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.spans
+}
+
+type testTracer struct {
+	embedded.Tracer
+	provider *testTracerProvider
+}
+
+func (t *testTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	parent := trace.SpanContextFromContext(ctx)
+	traceID := parent.TraceID()
+	if !traceID.IsValid() {
+		_, _ = rand.Read(traceID[:])
+	}
+	var spanID trace.SpanID
+	_, _ = rand.Read(spanID[:])
+
+	span := &testSpan{
+		provider: t.provider,
+		name:     name,
+		kind:     cfg.SpanKind(),
+		parent:   parent,
+		attrs:    cfg.Attributes(),
+		sc: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     spanID,
+			TraceFlags: trace.FlagsSampled,
+		}),
+	}
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+type testSpan struct {
+	noop.Span
+	provider *testTracerProvider
+
+	name   string
+	kind   trace.SpanKind
+	parent trace.SpanContext
+	sc     trace.SpanContext
+	attrs  []attribute.KeyValue
+	status codes.Code
+}
+
+func (s *testSpan) SpanContext() trace.SpanContext { return s.sc }
+
+func (s *testSpan) IsRecording() bool { return true }
+
+func (s *testSpan) SetAttributes(kv ...attribute.KeyValue) { s.attrs = append(s.attrs, kv...) }
+
+func (s *testSpan) SetStatus(code codes.Code, description string) { s.status = code }
+
+func (s *testSpan) End(options ...trace.SpanEndOption) {
+	s.provider.mu.Lock()
+	s.provider.spans = append(s.provider.spans, s)
+	s.provider.mu.Unlock()
+}
+
+func (s *testSpan) attr(key attribute.Key) attribute.Value {
+	for _, kv := range s.attrs {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer s.Close()
+
+	tp := &testTracerProvider{}
+	client := NewClient(
+		WithTracing(tp, nil),
+		WithTransport(NewTransport(WithTlsConnOptTracing(tp, nil))),
+	)
+	if _, _, err := client.NewRequest(http.MethodGet, s.URL+"/ok").String(); err != nil {
+		t.Fatal(err)
+	}
+	header := <-headers
+
+	spans := tp.ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	// 先结束的是 Transport 的 span
+	rt, do := spans[0], spans[1]
+	if do.kind != trace.SpanKindClient || do.name != http.MethodGet || do.parent.IsValid() {
+		t.Fatalf("Do span = %+v", do)
+	}
+	if rt.parent.SpanID() != do.sc.SpanID() || rt.sc.TraceID() != do.sc.TraceID() {
+		t.Fatal("RoundTrip span should be the child of Do span")
+	}
+	if got := do.attr("http.response.status_code").AsInt64(); got != http.StatusOK {
+		t.Fatalf("status code = %d", got)
+	}
+	if got := do.attr("url.full").AsString(); got != s.URL+"/ok" {
+		t.Fatalf("url.full = %s", got)
+	}
+
+	want := "00-" + rt.sc.TraceID().String() + "-" + rt.sc.SpanID().String() + "-01"
+	if got := header.Get("Traceparent"); got != want {
+		t.Fatalf("traceparent = %s, want %s", got, want)
+	}
+
+	if _, _, err := client.NewRequest(http.MethodGet, s.URL+"/fail").String(); err != nil {
+		t.Fatal(err)
+	}
+	<-headers
+	if spans = tp.ended(); spans[3].status != codes.Error {
+		t.Fatalf("status = %v, want error", spans[3].status)
+	}
+}
+
+func TestMetadataHeaders(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header
+	}))
+	defer s.Close()
+
+	client := NewClient(
+		WithMetadata(map[string]string{"tenant": "default", "trace-id": "abc"}),
+		WithMetadataHeaders(map[string]string{"tenant": "X-Tenant-Id", "trace-id": "X-Trace-Id", "user": "X-User"}),
+	)
+
+	tests := []struct {
+		ctx    context.Context
+		head   string
+		tenant string
+	}{
+		{context.Background(), "", "default"},
+		{metadata.AppendToRequestContext(context.Background(), "tenant", "t1"), "", "t1"},
+		{context.Background(), "t2", "t2"},
+	}
+	for _, tt := range tests {
+		req := client.NewRequestWithContext(tt.ctx, http.MethodGet, s.URL)
+		if tt.head != "" {
+			req.SetHead("X-Tenant-Id", tt.head)
+		}
+		if _, _, err := req.String(); err != nil {
+			t.Fatal(err)
+		}
+		header := <-headers
+		if got := header.Get("X-Tenant-Id"); got != tt.tenant {
+			t.Errorf("X-Tenant-Id = %q, want %q", got, tt.tenant)
+		}
+		if got := header.Get("X-Trace-Id"); got != "abc" {
+			t.Errorf("X-Trace-Id = %q, want abc", got)
+		}
+		if _, ok := header["X-User"]; ok {
+			t.Error("X-User should not be set")
+		}
+	}
+}
@@ -1,12 +1,15 @@
 package httpclient
 
 import (
+	"context"
+	"crypto/x509"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
-	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -77,7 +80,7 @@ func TestTransport_no_proxy_mutil_host_default(t *testing.T) {
 		t.Fatal("TestTransport_no_proxy_mutil_host_default test https failed")
 	}
 	count := 0
-	for host, _ := range tr.hostToInnerTransportMap {
+	for _, host := range tr.hostToInnerTransportLRU.keys() {
 		count++
 		t.Logf("TestTransport_no_proxy_mutil_host_default transport host: %s", host)
 	}
@@ -126,7 +129,7 @@ func TestTransport_proxy_mutil_host(t *testing.T) {
 		t.Fatal("TestTransport_proxy_mutil_host test https koreanair failed")
 	}
 	count := 0
-	for host, _ := range tr.hostToInnerTransportMap {
+	for _, host := range tr.hostToInnerTransportLRU.keys() {
 		count++
 		t.Logf("TestTransport_proxy_mutil_host transport host: %s", host)
 	}
@@ -179,7 +182,7 @@ func TestTransport_default_tls_mutil_host(t *testing.T) {
 		t.Fatal("TestTransport_default_tls_mutil_host test https koreanair failed")
 	}
 	count := 0
-	for host, _ := range tr.hostToInnerTransportMap {
+	for _, host := range tr.hostToInnerTransportLRU.keys() {
 		count++
 		t.Logf("TestTransport_default_tls_mutil_host transport host: %s", host)
 	}
@@ -235,7 +238,7 @@ func TestTransport_custom_tls_mutil_host(t *testing.T) {
 		t.Fatal("TestTransport_custom_tls_mutil_host test https koreanair failed")
 	}
 	count := 0
-	for host, _ := range tr.hostToInnerTransportMap {
+	for _, host := range tr.hostToInnerTransportLRU.keys() {
 		count++
 		t.Logf("TestTransport_custom_tls_mutil_host transport host: %s", host)
 	}
@@ -307,7 +310,7 @@ func TestTransport_mutil_goroutine(t *testing.T) {
 	}
 	wg.Wait()
 	count := 0
-	for host, _ := range tr.hostToInnerTransportMap {
+	for _, host := range tr.hostToInnerTransportLRU.keys() {
 		count++
 		t.Logf("TestTransport_mutil_goroutine transport host: %s", host)
 	}
@@ -360,7 +363,7 @@ func TestTransport_reuse_transport(t *testing.T) {
 		}
 		wg.Wait()
 		t.Logf("TestTransport_reuse_transport host count is %d host:%s handshakeinfo:%d",
-			len(tr.hostToInnerTransportMap), urlAddr, tr.Debug.GetTlsConnCount(u.Host))
+			tr.TransportCount(), urlAddr, tr.Debug.GetTlsConnCount(u.Host))
 
 	}
 	t.Logf(" tr.Debug: %v", tr.Debug.TlsConnCount)
@@ -393,8 +396,8 @@ func TestTransport_redirect_transport(t *testing.T) {
 			t.Logf("TestTransport_redirect_transport num:%d code:%d\n", i, code)
 		}
 	}
-	if len(tr.hostToInnerTransportMap) != 2 {
-		t.Fatalf("TestTransport_redirect_transport host count should be 1, but %d", len(tr.hostToInnerTransportMap))
+	if tr.TransportCount() != 2 {
+		t.Fatalf("TestTransport_redirect_transport host count should be 1, but %d", tr.TransportCount())
 	}
 }
 
@@ -438,8 +441,8 @@ func TestTransport_common_finger_transport(t *testing.T) {
 			t.Logf("TestTransport_common_finger_transport code:%d\n", code)
 		}
 
-		if len(tr.hostToInnerTransportMap) != 1 {
-			t.Fatalf("TestTransport_common_finger_transport host count should be 1, but %d", len(tr.hostToInnerTransportMap))
+		if tr.TransportCount() != 1 {
+			t.Fatalf("TestTransport_common_finger_transport host count should be 1, but %d", tr.TransportCount())
 		}
 	}
 }
@@ -504,7 +507,8 @@ func TestTransport_timeout_handshake(t *testing.T) {
 	host := "www.baidu.com"
 	t.Logf("TestTransport_timeout_handshake host:%s cost:%d", host, tr.GetTlsHandShakeTime(host))
 	if err != nil {
-		if strings.Contains(err.Error(), "HandshakeContextError") {
+		var dialErr *TLSDialError
+		if errors.As(err, &dialErr) && dialErr.Op == "handshake" {
 			t.Logf("TestTransport_timeout_handshake %s", err.Error())
 			return
 		}
@@ -512,3 +516,75 @@ func TestTransport_timeout_handshake(t *testing.T) {
 	}
 
 }
+
+func TestTlsAddr(t *testing.T) {
+	tests := []struct {
+		hostport, addr, serverName string
+	}{
+		{"example.com", "example.com:443", "example.com"},
+		{"example.com:443", "example.com:443", "example.com"},
+		{"example.com:8443", "example.com:8443", "example.com"},
+		{"127.0.0.1:8443", "127.0.0.1:8443", "127.0.0.1"},
+		{"[::1]:8443", "[::1]:8443", "::1"},
+		{"[::1]", "[::1]:443", "::1"},
+	}
+	for _, tt := range tests {
+		addr, serverName := tlsAddr(tt.hostport)
+		if addr != tt.addr || serverName != tt.serverName {
+			t.Errorf("tlsAddr(%q) = %s, %s, want %s, %s", tt.hostport, addr, serverName, tt.addr, tt.serverName)
+		}
+	}
+}
+
+// 非443端口可以正常握手 自签名证书校验失败说明连接到了正确的端口
+func TestGetTlsConn_Port(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	tr := NewTransport()
+	_, err := tr.getTlsConn(context.Background(), s.Listener.Addr().String(), nil)
+	var dialErr *TLSDialError
+	if !errors.As(err, &dialErr) || dialErr.Op != "handshake" {
+		t.Fatalf("err = %v, want handshake error", err)
+	}
+	var certErr x509.UnknownAuthorityError
+	if !errors.As(err, &certErr) {
+		t.Fatalf("err = %v, want certificate error", err)
+	}
+}
+
+// 请求取消时立即停止卡住的握手
+func TestGetTlsConn_Cancel(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	tr := NewTransport(WithHandShakeTimeout(time.Minute))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+ln.Addr().String()+"/", nil)
+
+	start := time.Now()
+	_, err = tr.RoundTrip(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	var dialErr *TLSDialError
+	if !errors.As(err, &dialErr) || dialErr.Op != "handshake" || !dialErr.Timeout() {
+		t.Fatalf("err = %#v, want handshake timeout", err)
+	}
+	if cost := time.Since(start); cost > 2*time.Second {
+		t.Fatalf("RoundTrip took %v after cancel", cost)
+	}
+}
@@ -0,0 +1,117 @@
+package httpclient
+
+import (
+	"container/list"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultMaxHostTransports = 512
+	defaultHostTransportTTL  = 5 * time.Minute
+
+	// 淘汰时正在使用的连接之后才会空闲 延迟再关闭一次
+	retiredTransportCloseDelay = time.Minute
+)
+
+// transportCache 每个host的transport 超过数量时淘汰最久未使用的 超过 ttl 未使用的也会淘汰
+// 淘汰时关闭空闲连接 正在进行的请求不受影响 调用方加锁
+type transportCache struct {
+	maxSize int           // <=0 不限制数量
+	ttl     time.Duration // <=0 不按时间淘汰
+	ll      *list.List
+	items   map[string]*list.Element
+}
+
+type transportEntry struct {
+	key      string
+	rt       http.RoundTripper
+	lastUsed time.Time
+}
+
+func newTransportCache(maxSize int, ttl time.Duration) *transportCache {
+	return &transportCache{
+		maxSize: maxSize,
+		ttl:     ttl,
+		ll:      list.New(),
+		items:   make(map[string]*list.Element),
+	}
+}
+
+func (c *transportCache) get(key string, now time.Time) (http.RoundTripper, bool) {
+	c.removeExpired(now)
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*transportEntry)
+	entry.lastUsed = now
+	c.ll.MoveToFront(elem)
+	return entry.rt, true
+}
+
+func (c *transportCache) add(key string, rt http.RoundTripper, now time.Time) {
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+
+	c.items[key] = c.ll.PushFront(&transportEntry{key: key, rt: rt, lastUsed: now})
+	for c.maxSize > 0 && c.ll.Len() > c.maxSize {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *transportCache) remove(key string) {
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// removeExpired 从最久未使用的开始淘汰超过 ttl 的
+func (c *transportCache) removeExpired(now time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	for elem := c.ll.Back(); elem != nil; elem = c.ll.Back() {
+		if now.Sub(elem.Value.(*transportEntry).lastUsed) < c.ttl {
+			return
+		}
+		c.removeElement(elem)
+	}
+}
+
+func (c *transportCache) clear() {
+	for elem := c.ll.Back(); elem != nil; elem = c.ll.Back() {
+		c.removeElement(elem)
+	}
+}
+
+func (c *transportCache) removeElement(elem *list.Element) {
+	entry := c.ll.Remove(elem).(*transportEntry)
+	delete(c.items, entry.key)
+	closeIdleConnections(entry.rt)
+	time.AfterFunc(retiredTransportCloseDelay, func() {
+		closeIdleConnections(entry.rt)
+	})
+}
+
+func (c *transportCache) len() int {
+	return c.ll.Len()
+}
+
+// keys 从最近使用到最久未使用
+func (c *transportCache) keys() []string {
+	keys := make([]string, 0, c.ll.Len())
+	for elem := c.ll.Front(); elem != nil; elem = elem.Next() {
+		keys = append(keys, elem.Value.(*transportEntry).key)
+	}
+	return keys
+}
+
+func closeIdleConnections(rt http.RoundTripper) {
+	if closer, ok := rt.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
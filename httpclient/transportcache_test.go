@@ -0,0 +1,145 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type idleRoundTripper struct {
+	http.RoundTripper
+	closed atomic.Int32
+}
+
+func (rt *idleRoundTripper) CloseIdleConnections() {
+	rt.closed.Add(1)
+}
+
+func TestTransportCache(t *testing.T) {
+	now := time.Now()
+	c := newTransportCache(2, time.Minute)
+	a, b, d := &idleRoundTripper{}, &idleRoundTripper{}, &idleRoundTripper{}
+
+	c.add("a", a, now)
+	c.add("b", b, now)
+	if _, ok := c.get("a", now); !ok {
+		t.Fatal("a should be cached")
+	}
+
+	// 超过数量淘汰最久未使用的 b
+	c.add("d", d, now)
+	if got := strings.Join(c.keys(), ","); got != "d,a" {
+		t.Fatalf("keys = %s, want d,a", got)
+	}
+	if b.closed.Load() != 1 || a.closed.Load() != 0 {
+		t.Fatalf("closed a = %d, b = %d", a.closed.Load(), b.closed.Load())
+	}
+
+	// 超过 ttl 未使用的被淘汰
+	if _, ok := c.get("d", now.Add(30*time.Second)); !ok {
+		t.Fatal("d should be cached")
+	}
+	if _, ok := c.get("a", now.Add(70*time.Second)); ok {
+		t.Fatal("a should be expired")
+	}
+	if c.len() != 1 || a.closed.Load() != 1 {
+		t.Fatalf("len = %d, closed a = %d", c.len(), a.closed.Load())
+	}
+
+	c.clear()
+	if c.len() != 0 || d.closed.Load() != 1 {
+		t.Fatalf("len = %d, closed d = %d", c.len(), d.closed.Load())
+	}
+}
+
+func TestTransportCache_Transport(t *testing.T) {
+	var urls []string
+	for i := 0; i < 3; i++ {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer s.Close()
+		urls = append(urls, s.URL)
+	}
+
+	tr := NewTransport(WithTlsConnOptMaxHosts(2))
+	client := &http.Client{Transport: tr}
+	for _, u := range urls {
+		resp, err := client.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if n := tr.TransportCount(); n != 2 {
+		t.Fatalf("TransportCount() = %d, want 2", n)
+	}
+
+	client.CloseIdleConnections()
+	if n := tr.TransportCount(); n != 0 {
+		t.Fatalf("TransportCount() = %d after CloseIdleConnections, want 0", n)
+	}
+}
+
+func TestTransportCache_ConcurrentDial(t *testing.T) {
+	// 接受连接但不握手
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	var accepted atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			defer conn.Close()
+		}
+	}()
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer s.Close()
+
+	tr := NewTransport(WithTlsConnOptInsecureSkipVerify(true))
+	hang := "https://" + ln.Addr().String()
+	get := func(timeout time.Duration, url string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		resp, err := tr.RoundTrip(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	leader := make(chan error, 1)
+	go func() { leader <- get(2*time.Second, hang) }()
+	for accepted.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 等待同一个host的握手时 ctx 取消直接返回
+	start := time.Now()
+	if err := get(100*time.Millisecond, hang); !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Second {
+		t.Fatalf("waiter err = %v after %v", err, time.Since(start))
+	}
+	// 其他host不受影响
+	if err := get(time.Second, s.URL); err != nil {
+		t.Fatalf("other host blocked: %v", err)
+	}
+	if n := accepted.Load(); n != 1 {
+		t.Fatalf("accepted = %d, want 1", n)
+	}
+	if err := <-leader; err == nil {
+		t.Fatal("handshake with a silent server should fail")
+	}
+}